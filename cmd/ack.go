@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ackBy      string
+	ackComment string
+)
+
+// ackCmd represents the ack command
+var ackCmd = &cobra.Command{
+	Use:   "ack [CONTAINER CHECK]",
+	Short: "acknowledge an active alert",
+	Long: `Acknowledge the active alert of a check through the API of the running daemon, it is
+not reminded nor escalated anymore until it recovers. Without arguments, the active alerts
+are listed, e.g.:
+
+docker-alertd ack web cpu --comment "looking into it"`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return errors.New("expected a container and a check")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			var alerts []ActiveAlert
+			if err := apiCall(http.MethodGet, "/api/alerts", nil, &alerts); err != nil {
+				log.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "CONTAINER\tCHECK\tSINCE\tACKNOWLEDGED BY\tTITLE")
+			for _, active := range alerts {
+				by := ""
+				if active.Ack != nil {
+					by = active.Ack.By + " " + active.Ack.At.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", active.Alert.Container, active.Alert.Check,
+					active.Alert.Since.Format(time.RFC3339), by, active.Alert.Title)
+			}
+			w.Flush()
+			return
+		}
+
+		if ackBy == "" {
+			ackBy = os.Getenv("USER")
+		}
+
+		req := ackRequest{Container: args[0], Check: args[1], By: ackBy, Comment: ackComment}
+		if err := apiCall(http.MethodPost, "/api/alerts/ack", req, nil); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// apiCall calls the API of the running daemon, the response is decoded in out
+func apiCall(method, path string, in, out interface{}) error {
+	base := strings.TrimRight(Config.API.URL, "/")
+	switch {
+	case base != "":
+	case Config.API.Address == "":
+		return ErrAPIAddress
+	case strings.HasPrefix(Config.API.Address, ":"):
+		base = "http://127.0.0.1" + Config.API.Address
+	default:
+		base = "http://" + Config.API.Address
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if Config.API.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+Config.API.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error calling the api")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return errors.Errorf("api responded with status %d: %s", resp.StatusCode, e.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func init() {
+	RootCmd.AddCommand(ackCmd)
+
+	ackCmd.Flags().StringVar(&ackBy, "by", "", "author of the acknowledgement (default $USER)")
+	ackCmd.Flags().StringVar(&ackComment, "comment", "", "comment of the acknowledgement")
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Acknowledgement records who acknowledged an active alert and when, the acknowledged
+// alerts are not reminded nor escalated until they recover
+type Acknowledgement struct {
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// ActiveAlert is the failure of a check which has not recovered yet
+type ActiveAlert struct {
+	Alert     Alert            `json:"alert"`
+	Ack       *Acknowledgement `json:"ack,omitempty"`
+	Notified  time.Time        `json:"notified"`
+	Inhibited bool             `json:"inhibited,omitempty"`
+}
+
+// alertRegistry tracks the active alerts, it is shared by the monitor loop and the API
+type alertRegistry struct {
+	sync.Mutex
+	alerts map[string]*ActiveAlert
+}
+
+// activeAlerts are the failures of the monitored checks which have not recovered yet
+var activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+// Activate adds the failure of a check
+func (r *alertRegistry) Activate(a Alert) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.alerts[a.Key()] = &ActiveAlert{Alert: a, Notified: time.Now()}
+}
+
+// Restore adds an active alert saved by a previous run
+func (r *alertRegistry) Restore(active ActiveAlert) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.alerts[active.Alert.Key()] = &active
+}
+
+// Resolve removes the alert of a check which recovered
+func (r *alertRegistry) Resolve(key string) {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.alerts, key)
+}
+
+// Acknowledge acknowledges the active alert of a check, since is the activation time of
+// the acknowledged failure, a zero since acknowledges the current one
+func (r *alertRegistry) Acknowledge(key string, since time.Time, ack Acknowledgement) (Alert, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	active, ok := r.alerts[key]
+	switch {
+	case !ok || (!since.IsZero() && !since.Equal(active.Alert.Since)):
+		return Alert{}, errors.Wrap(ErrAckNotActive, key)
+	case active.Ack != nil:
+		return active.Alert, errors.Wrapf(ErrAckAlready, "%s by %s", key, active.Ack.By)
+	}
+
+	if ack.At.IsZero() {
+		ack.At = time.Now()
+	}
+	active.Ack = &ack
+
+	return active.Alert, nil
+}
+
+// Inhibit marks the active alert of a check as inhibited by a dependency which is down, it is
+// not reminded nor escalated while it is inhibited
+func (r *alertRegistry) Inhibit(key string, inhibited bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if active, ok := r.alerts[key]; ok {
+		active.Inhibited = inhibited
+	}
+}
+
+// Acknowledged returns the acknowledgement of the active alert of a check, if any
+func (r *alertRegistry) Acknowledged(key string) *Acknowledgement {
+	r.Lock()
+	defer r.Unlock()
+
+	if active, ok := r.alerts[key]; ok {
+		return active.Ack
+	}
+
+	return nil
+}
+
+// List returns the active alerts ordered by activation
+func (r *alertRegistry) List() []ActiveAlert {
+	r.Lock()
+	defer r.Unlock()
+
+	list := []ActiveAlert{}
+	for _, active := range r.alerts {
+		list = append(list, *active)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Alert.Since.Before(list[j].Alert.Since) })
+
+	return list
+}
+
+// Remind adds a reminder of the active alerts which are not acknowledged and have not been
+// notified for the interval
+func (r *alertRegistry) Remind(interval time.Duration, t *TemplateConfig, a *AlertList) {
+	if interval == 0 {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	for _, active := range r.alerts {
+		if active.Ack != nil || active.Inhibited || now.Sub(active.Notified) < interval {
+			continue
+		}
+		active.Notified = now
+
+		var message bytes.Buffer
+		var title bytes.Buffer
+
+		t.Executor.ExecuteTemplate(&message, "reminder-message", active.Alert)
+		t.Executor.ExecuteTemplate(&title, "reminder-title", active.Alert)
+
+		reminder := active.Alert
+		reminder.Message, reminder.Title, reminder.Time = message.String(), title.String(), now
+		reminder.FollowUp = true
+		a.AddAlert(reminder)
+	}
+}
+
+// SyncAcks marks the active checks of the container with their acknowledgement
+func (c *AlertdContainer) SyncAcks() {
+	for _, check := range []string{CheckExist, CheckRunning, CheckCPU, CheckMinPID, CheckMemory} {
+		if ack := activeAlerts.Acknowledged(c.Name + "/" + check); ack != nil {
+			c.Acknowledge(check, ack)
+		}
+	}
+}
+
+// Acknowledge marks the alert of a check as acknowledged
+func (c *AlertdContainer) Acknowledge(check string, ack *Acknowledgement) {
+	switch check {
+	case CheckExist:
+		c.ExistenceCheck.Ack = ack
+	case CheckRunning:
+		c.RunningCheck.Ack = ack
+	case CheckCPU:
+		c.CPUCheck.Ack = ack
+	case CheckMinPID:
+		c.PIDCheck.Ack = ack
+	case CheckMemory:
+		c.MemCheck.Ack = ack
+	}
+}
+
+// ackSignature returns the signature of the acknowledgement link of a failure
+func ackSignature(token, container, check string, since int64) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	fmt.Fprintf(mac, "%s\n%s\n%d", container, check, since)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AckURL returns the signed acknowledgement link of a failure, it is only valid for this
+// failure of the check. There is no link without an API URL and token.
+func (api API) AckURL(a Alert) string {
+	if api.URL == "" || api.Token == "" || a.State != StateFailure || a.Check == "" {
+		return ""
+	}
+
+	since := a.Since.UnixNano()
+	query := url.Values{
+		"container": {a.Container},
+		"check":     {a.Check},
+		"since":     {fmt.Sprint(since)},
+		"sig":       {ackSignature(api.Token, a.Container, a.Check, since)},
+	}
+
+	return strings.TrimRight(api.URL, "/") + "/api/ack?" + query.Encode()
+}
+
+// AcknowledgeAlert acknowledges an active alert and sends a notice of the acknowledgement
+func AcknowledgeAlert(c *Conf, container, check string, since time.Time, ack Acknowledgement) error {
+	alert, err := activeAlerts.Acknowledge(container+"/"+check, since, ack)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s/%s acknowledged by %s\n", container, check, ack.By)
+
+	var message bytes.Buffer
+	var title bytes.Buffer
+
+	data := struct {
+		Alert
+		Acknowledgement
+	}{alert, ack}
+
+	c.Templates.Executor.ExecuteTemplate(&message, "acknowledged-message", data)
+	c.Templates.Executor.ExecuteTemplate(&title, "acknowledged-title", data)
+
+	a := &AlertList{Alerts: []Alert{}}
+	a.AddAlert(Alert{Message: message.String(), Title: title.String(), Container: container,
+		Check: check, Severity: alert.Severity, State: StateInfo})
+	a.Evaluate()
+
+	return nil
+}
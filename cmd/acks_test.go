@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcknowledgement(t *testing.T) {
+	defer func(r *alertRegistry) { activeAlerts = r }(activeAlerts)
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+	templates, err := TemplateConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Conf{Templates: templates, API: API{Token: "secret", URL: "http://alertd.example.com/"}}
+
+	failure := Alert{Container: "web", Check: CheckCPU, Title: "CPU check failure",
+		State: StateFailure, Since: time.Now().Add(-time.Hour)}
+	activeAlerts.Activate(failure)
+	activeAlerts.alerts[failure.Key()].Notified = failure.Since
+
+	a := &AlertList{}
+	activeAlerts.Remind(30*time.Minute, &c.Templates, a)
+	if len(a.Alerts) != 1 || a.Alerts[0].Title != "Reminder: CPU check failure" {
+		t.Fatalf("expected a reminder, got %+v", a.Alerts)
+	}
+
+	// the link of the failure acknowledges it once its form is submitted
+	link := c.API.AckURL(failure)
+	if !strings.HasPrefix(link, "http://alertd.example.com/api/ack?") {
+		t.Fatalf("unexpected link %q", link)
+	}
+
+	server := httptest.NewServer(c.API.Handler(c))
+	defer server.Close()
+
+	for _, expected := range []struct {
+		method string
+		url    string
+		code   int
+	}{
+		{http.MethodGet, strings.Replace(link, "sig=", "sig=0", 1), http.StatusForbidden},
+		{http.MethodGet, link, http.StatusOK},
+		{http.MethodPost, strings.Replace(link, "sig=", "sig=0", 1), http.StatusForbidden},
+		{http.MethodPost, link, http.StatusOK},
+		{http.MethodPost, link, http.StatusConflict},
+	} {
+		url := strings.Replace(expected.url, "http://alertd.example.com", server.URL, 1)
+		resp, err := http.DefaultClient.Do(func() *http.Request {
+			req, _ := http.NewRequest(expected.method, url, strings.NewReader("comment=on+it"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expected.code {
+			t.Errorf("expected status %d for %s, got %d", expected.code, expected.method, resp.StatusCode)
+		}
+		if expected.method == http.MethodGet && activeAlerts.Acknowledged(failure.Key()) != nil {
+			t.Fatal("expected the link not to acknowledge the alert before the form is submitted")
+		}
+	}
+
+	ack := activeAlerts.Acknowledged(failure.Key())
+	if ack == nil || !strings.HasPrefix(ack.By, "link from ") || ack.Comment != "on it" || ack.At.IsZero() {
+		t.Fatalf("expected the acknowledgement to be recorded, got %+v", ack)
+	}
+
+	// the acknowledged alerts are not reminded anymore
+	activeAlerts.alerts[failure.Key()].Notified = failure.Since
+	a = &AlertList{}
+	activeAlerts.Remind(30*time.Minute, &c.Templates, a)
+	if len(a.Alerts) != 0 {
+		t.Errorf("expected no reminder, got %+v", a.Alerts)
+	}
+
+	// the checks of the container are marked
+	cnt := InitCheckers(&Conf{Containers: []Container{{Name: "web"}}})[0]
+	cnt.CPUCheck.AlertActive = true
+	cnt.SyncAcks()
+	if cnt.CPUCheck.Ack != ack {
+		t.Error("expected the cpu check to be acknowledged")
+	}
+
+	activeAlerts.Resolve(failure.Key())
+	if err := AcknowledgeAlert(c, "web", CheckCPU, time.Time{}, Acknowledgement{By: "me"}); err == nil {
+		t.Error("expected an error acknowledging a recovered alert")
+	}
+}
@@ -12,30 +12,49 @@ import (
 // MetricCheck stores the name of the alert, a function, and a active boolean
 type MetricCheck struct {
 	AlertActive bool
+	ActiveSince	time.Time
 	Limit       *uint64
 	MinDelay	*uint64
 	Delaying	bool
 	DelaySince	time.Time
+	Ack			*Acknowledgement
 }
 
 // ToggleAlertActive changes the state of the alert
 func (c *MetricCheck) ToggleAlertActive() {
 	c.AlertActive = !c.AlertActive
+	c.Ack = nil
+	if c.AlertActive {
+		c.ActiveSince = time.Now()
+	}
+}
+
+// MetricData is the data of the templates of the metric checks
+type MetricData struct {
+	Name	string
+	Limit	uint64
+	Usage	uint64
 }
 
 // StaticCheck checks the container for some static thing that is not based on usage
 // statistics, like its existence, whether it is running or not, etc.
 type StaticCheck struct {
 	AlertActive bool
+	ActiveSince	time.Time
 	Expected    *bool
 	MinDelay	*uint64
 	Delaying	bool
 	DelaySince	time.Time
+	Ack			*Acknowledgement
 }
 
 // ToggleAlertActive changes the state of the alert
 func (c *StaticCheck) ToggleAlertActive() {
 	c.AlertActive = !c.AlertActive
+	c.Ack = nil
+	if c.AlertActive {
+		c.ActiveSince = time.Now()
+	}
 }
 
 // Checker interface has all of the methods necessary to check a container
@@ -61,6 +80,71 @@ type AlertdContainer struct {
 	RunningCheck   *StaticCheck
 	
 	Templates	*TemplateConfig
+	Severity	string
+	Project		string
+	Labels		map[string]string
+}
+
+// AddAlert renders the title and message templates of a check for the given state and adds
+// the result to the alert list of the container
+func (c *AlertdContainer) AddAlert(check string, state AlertState, data interface{}) {
+	var message bytes.Buffer
+	var title bytes.Buffer
+	
+	c.Templates.Executor.ExecuteTemplate(&message, fmt.Sprintf("%s-%s-message", check, state), data)
+	c.Templates.Executor.ExecuteTemplate(&title, fmt.Sprintf("%s-%s-title", check, state), data)
+	
+	alert := Alert{
+		Message:	message.String(),
+		Title:		title.String(),
+		Container:	c.Name,
+		Project:	c.Project,
+		Labels:		c.Labels,
+		Check:		check,
+		State:		state,
+		Severity:	c.Severity,
+		Since:		time.Now(),
+	}
+	
+	if m, ok := data.(MetricData); ok {
+		alert.Value = &m.Usage
+		alert.Limit = &m.Limit
+	}
+	
+	// the check is toggled after the alert is added, a recovery still has the time of its
+	// failure
+	if since := c.ActiveSince(check); state == StateRecovery && !since.IsZero() {
+		alert.Since = since
+	}
+	
+	// the active failures can be acknowledged until they recover
+	switch state {
+	case StateFailure:
+		alert.AckURL = Config.API.AckURL(alert)
+		activeAlerts.Activate(alert)
+	case StateRecovery:
+		activeAlerts.Resolve(alert.Key())
+	}
+	
+	c.AlertList.AddAlert(alert)
+}
+
+// ActiveSince returns the time at which the alert of a check became active
+func (c *AlertdContainer) ActiveSince(check string) time.Time {
+	switch check {
+	case CheckExist:
+		return c.ExistenceCheck.ActiveSince
+	case CheckRunning:
+		return c.RunningCheck.ActiveSince
+	case CheckCPU:
+		return c.CPUCheck.ActiveSince
+	case CheckMinPID:
+		return c.PIDCheck.ActiveSince
+	case CheckMemory:
+		return c.MemCheck.ActiveSince
+	default:
+		return time.Time{}
+	}
 }
 
 // CheckMetrics checks everything where the Limit is not 0, there is no return because the
@@ -68,7 +152,7 @@ type AlertdContainer struct {
 func (c *AlertdContainer) CheckMetrics(s *types.Stats, e error) {
 	switch {
 	case e != nil:
-		c.AlertList.Add("Received an unknown error", "", e)
+		c.AlertList.AddAlert(Alert{Message: ErrUnknown.Error(), Error: e, Container: c.Name, State: StateInfo})
 	default:
 		if c.CPUCheck.Limit != nil {
 			c.CheckCPUUsage(s)
@@ -84,6 +168,11 @@ func (c *AlertdContainer) CheckMetrics(s *types.Stats, e error) {
 
 // CheckStatics will run all of the static checks that are listed for a container.
 func (c *AlertdContainer) CheckStatics(j *types.ContainerJSON, e error) {
+	if j != nil && j.Config != nil {
+		c.Project = j.Config.Labels[composeProjectLabel]
+		c.Labels = j.Config.Labels
+	}
+	
 	c.CheckExist(e)
 	if j != nil && c.RunningCheck.Expected != nil {
 		c.CheckRunning(j)
@@ -131,9 +220,6 @@ func (c *AlertdContainer) HasBecomeKnown(e error) bool {
 
 // CheckExist checks that the container exists, running or not
 func (c *AlertdContainer) CheckExist(e error) {
-	var message bytes.Buffer
-	var title bytes.Buffer
-	
 	data := struct {
 		Name		string
 	}{
@@ -147,10 +233,7 @@ func (c *AlertdContainer) CheckExist(e error) {
 		}
 		
 		// if the alert is not active I need to alert and make it active
-		c.Templates.Executor.ExecuteTemplate(&message, "exist-failure-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "exist-failure-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckExist, StateFailure, data)
 		
 		c.ExistenceCheck.ToggleAlertActive()
 
@@ -158,15 +241,12 @@ func (c *AlertdContainer) CheckExist(e error) {
 		// do nothing
 	case c.HasErrored(e):
 		// if there is some other error besides an existence check error
-		c.AlertList.Add(fmt.Sprintf("%s", c.Name), ErrUnknown.Error(), e)
+		c.AlertList.AddAlert(Alert{Message: c.Name, Title: ErrUnknown.Error(), Error: e, Container: c.Name, State: StateInfo})
 
 	case c.HasBecomeKnown(e):
 		c.ShouldDelayStatic(false, c.ExistenceCheck)
 		
-		c.Templates.Executor.ExecuteTemplate(&message, "exist-recovery-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "exist-recovery-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckExist, StateRecovery, data)
 		
 		c.ExistenceCheck.ToggleAlertActive()
 	default:
@@ -188,9 +268,6 @@ func (c *AlertdContainer) CheckRunning(j *types.ContainerJSON) {
 		return
 	}
 	
-	var message bytes.Buffer
-	var title bytes.Buffer
-	
 	data := struct {
 		Name		string
 		Expected	bool
@@ -203,18 +280,12 @@ func (c *AlertdContainer) CheckRunning(j *types.ContainerJSON) {
 	
 	switch {
 	case a && !c.RunningCheck.AlertActive:
-		c.Templates.Executor.ExecuteTemplate(&message, "running-failure-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "running-failure-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckRunning, StateFailure, data)
 
 		c.RunningCheck.ToggleAlertActive()
 
 	case !a && c.RunningCheck.AlertActive:
-		c.Templates.Executor.ExecuteTemplate(&message, "running-recovery-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "running-recovery-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckRunning, StateRecovery, data)
 
 		c.RunningCheck.ToggleAlertActive()
 	}
@@ -249,14 +320,7 @@ func (c *AlertdContainer) CheckCPUUsage(s *types.Stats) {
 		return
 	}
 	
-	var message bytes.Buffer
-	var title bytes.Buffer
-	
-	data := struct {
-		Name	string
-		Limit	uint64
-		Usage	uint64
-	}{
+	data := MetricData{
 		c.Name,
 		*c.CPUCheck.Limit,
 		u,
@@ -264,18 +328,12 @@ func (c *AlertdContainer) CheckCPUUsage(s *types.Stats) {
 
 	switch {
 	case a && !c.CPUCheck.AlertActive:
-		c.Templates.Executor.ExecuteTemplate(&message, "cpu-failure-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "cpu-failure-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckCPU, StateFailure, data)
 
 		c.CPUCheck.ToggleAlertActive()
 
 	case !a && c.CPUCheck.AlertActive:
-		c.Templates.Executor.ExecuteTemplate(&message, "cpu-recovery-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "cpu-recovery-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckCPU, StateRecovery, data)
 
 		c.CPUCheck.ToggleAlertActive()
 	}
@@ -299,14 +357,7 @@ func (c *AlertdContainer) CheckMinPids(s *types.Stats) {
 		return
 	}
 	
-	var message bytes.Buffer
-	var title bytes.Buffer
-	
-	data := struct {
-		Name	string
-		Limit	uint64
-		Usage	uint64
-	}{
+	data := MetricData{
 		c.Name,
 		*c.PIDCheck.Limit,
 		s.PidsStats.Current,
@@ -314,18 +365,12 @@ func (c *AlertdContainer) CheckMinPids(s *types.Stats) {
 	
 	switch {
 	case a && !c.PIDCheck.AlertActive:
-		c.Templates.Executor.ExecuteTemplate(&message, "min-pid-failure-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "min-pid-failure-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckMinPID, StateFailure, data)
 
 		c.PIDCheck.ToggleAlertActive()
 
 	case !a && c.PIDCheck.AlertActive:
-		c.Templates.Executor.ExecuteTemplate(&message, "min-pid-recovery-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "min-pid-recovery-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckMinPID, StateRecovery, data)
 
 		c.PIDCheck.ToggleAlertActive()
 	}
@@ -356,32 +401,19 @@ func (c *AlertdContainer) CheckMemory(s *types.Stats) {
 		return
 	}
 	
-	var message bytes.Buffer
-	var title bytes.Buffer
-	
-	data := struct {
-		Name	string
-		Limit	uint64
-		Usage	uint64
-	}{
+	data := MetricData{
 		c.Name,
 		*c.MemCheck.Limit,
 		c.MemUsageMB(s),
 	}
 
 	if a && !c.MemCheck.AlertActive {
-		c.Templates.Executor.ExecuteTemplate(&message, "memory-failure-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "memory-failure-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckMemory, StateFailure, data)
 		
 		c.MemCheck.ToggleAlertActive()
 		
 	} else if !a && c.MemCheck.AlertActive {
-		c.Templates.Executor.ExecuteTemplate(&message, "memory-recovery-message", data)
-		c.Templates.Executor.ExecuteTemplate(&title, "memory-recovery-title", data)
-		
-		c.AlertList.Add(message.String(), title.String(), nil)
+		c.AddAlert(CheckMemory, StateRecovery, data)
 
 		c.MemCheck.ToggleAlertActive()
 	}
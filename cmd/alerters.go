@@ -2,13 +2,32 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
 	"net/http"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"net/url"
+	"os"
+	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -17,33 +36,362 @@ import (
 // and twitter/slack
 type Alerter interface {
 	Valid() error
-	Alert(a *AlertList) error
+	Alert(ctx context.Context, a *AlertList) error
 }
 
 // Email implements the Alerter interface and sends emails
 type Email struct {
-	SMTP     string
-	Password string
-	Port     string
-	From     string
-	To       []string
+	SMTP               string
+	Port               string
+	Encryption         string
+	InsecureSkipVerify bool
+	Auth               string
+	Username           string
+	Password           string
+	From               string
+	To                 []string
+	CC                 []string
+	BCC                []string
+	Subject            string
+	HTMLTemplate       string
+	PlainText          bool
+}
+
+// the encryption modes of the SMTP connection
+const (
+	EmailOpportunistic = "opportunistic" // STARTTLS when the server supports it
+	EmailSTARTTLS      = "starttls"      // STARTTLS is required
+	EmailTLS           = "tls"           // implicit TLS, usually on port 465
+	EmailNoEncryption  = "none"
+)
+
+// encryption returns the encryption mode, port 465 defaults to implicit TLS
+func (e Email) encryption() string {
+	switch {
+	case e.Encryption != "":
+		return strings.ToLower(e.Encryption)
+	case e.Port == "465":
+		return EmailTLS
+	default:
+		return EmailOpportunistic
+	}
+}
+
+// auth returns the SMTP authentication, nil when the server is a relay without
+// authentication. The password alone enables PLAIN for backward compatibility.
+func (e Email) auth() (smtp.Auth, error) {
+	username := e.Username
+	if username == "" {
+		username = e.From
+	}
+
+	mechanism := strings.ToLower(e.Auth)
+	if mechanism == "" && e.Password != "" {
+		mechanism = "plain"
+	}
+
+	switch mechanism {
+	case "", "none":
+		return nil, nil
+	case "plain":
+		return smtp.PlainAuth("", username, e.Password, e.SMTP), nil
+	case "login":
+		return &loginAuth{username: username, password: e.Password, host: e.SMTP}, nil
+	case "cram-md5":
+		return smtp.CRAMMD5Auth(username, e.Password), nil
+	default:
+		return nil, ErrEmailAuth
+	}
+}
+
+// Message returns the RFC 5322 message of the alerts, the subject is encoded and the UTF-8
+// body is quoted-printable. Bcc recipients are not part of the headers.
+func (e Email) Message(a *AlertList, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+
+	contentType := "text/plain; charset=UTF-8"
+	encoding := "quoted-printable"
+
+	if e.PlainText {
+		if err := writeQuotedPrintable(&body, a.DumpEmail()); err != nil {
+			return nil, err
+		}
+	} else {
+		html, err := e.HTML(a)
+		if err != nil {
+			return nil, err
+		}
+
+		// the last part of a multipart/alternative is the preferred one
+		mw := multipart.NewWriter(&body)
+		for _, part := range [][2]string{
+			{"text/plain; charset=UTF-8", a.DumpEmail()},
+			{"text/html; charset=UTF-8", html},
+		} {
+			w, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part[0]},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := writeQuotedPrintable(w, part[1]); err != nil {
+				return nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+
+		contentType = "multipart/alternative; boundary=" + mw.Boundary()
+		encoding = ""
+	}
+
+	from, err := mail.ParseAddress(e.From)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid email from address")
+	}
+
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i != -1 {
+		domain = from.Address[i+1:]
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", formatAddresses(e.To)},
+		{"Cc", formatAddresses(e.CC)},
+		{"Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(e.Subject+" "+a.Title()))},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%d.%x@%s>", date.UnixNano(), id, domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", contentType},
+		{"Content-Transfer-Encoding", encoding},
+	}
+
+	var msg bytes.Buffer
+	for _, h := range headers {
+		if h[1] != "" {
+			fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+		}
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	msg.WriteString("\r\n")
+
+	return msg.Bytes(), nil
+}
+
+// writeQuotedPrintable writes the text with the quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
+
+// emailTemplateData is the data of the HTML email template
+type emailTemplateData struct {
 	Subject  string
+	Host     string
+	Date     time.Time
+	Alerts   []Alert
+	Fallback *Fallback
+}
+
+// htmlTemplate returns the HTML template of the emails, the default one or the one loaded
+// from the HTMLTemplate file
+func (e Email) htmlTemplate() (*htmltemplate.Template, error) {
+	t := htmltemplate.New("email").Funcs(htmltemplate.FuncMap{
+		"since": func(t time.Time, now time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return now.Sub(t).Round(time.Second).String()
+		},
+	})
+
+	if e.HTMLTemplate == "" {
+		return t.Parse(DefaultEmailHTMLTemplate)
+	}
+
+	b, err := ioutil.ReadFile(e.HTMLTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading email html template")
+	}
+
+	t, err = t.Parse(string(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing email html template")
+	}
+
+	return t, nil
+}
+
+// HTML renders the alerts with the HTML template
+func (e Email) HTML(a *AlertList) (string, error) {
+	t, err := e.htmlTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, emailTemplateData{
+		Subject:  strings.TrimSpace(e.Subject + " " + a.Title()),
+		Host:     Config.Hostname,
+		Date:     time.Now(),
+		Alerts:   a.Alerts,
+		Fallback: a.Fallback,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "error executing email html template")
+	}
+
+	return b.String(), nil
+}
+
+// formatAddresses returns the addresses for a header, display names are encoded if needed
+func formatAddresses(list []string) string {
+	addresses := []string{}
+	for _, s := range list {
+		if address, err := mail.ParseAddress(s); err == nil {
+			addresses = append(addresses, address.String())
+		}
+	}
+
+	return strings.Join(addresses, ", ")
+}
+
+// recipients returns the envelope addresses of every To, Cc and Bcc recipient
+func (e Email) recipients() ([]string, error) {
+	recipients := []string{}
+	for _, list := range [][]string{e.To, e.CC, e.BCC} {
+		for _, s := range list {
+			address, err := mail.ParseAddress(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid email address %s", s)
+			}
+			recipients = append(recipients, address.Address)
+		}
+	}
+
+	return recipients, nil
+}
+
+// dial opens the SMTP session, with implicit TLS or upgraded with STARTTLS depending on the
+// encryption mode. The session ends at the deadline of the context.
+func (e Email) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(e.SMTP, e.Port)
+	config := &tls.Config{ServerName: e.SMTP, InsecureSkipVerify: e.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+
+	if e.encryption() == EmailTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, e.SMTP)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if Config.Hostname != "" {
+		if err := c.Hello(Config.Hostname); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	switch e.encryption() {
+	case EmailOpportunistic, EmailSTARTTLS:
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			err = c.StartTLS(config)
+		} else if e.encryption() == EmailSTARTTLS {
+			err = errors.New("the server does not support STARTTLS")
+		}
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
 }
 
 // Alert sends an email alert
-func (e Email) Alert(a *AlertList) error {
-	// The email message formatted properly
-	formattedMsg := []byte(fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", e.To, strings.TrimSpace(e.Subject + a.Title()), a.DumpEmail()))
+func (e Email) Alert(ctx context.Context, a *AlertList) error {
+	msg, err := e.Message(a, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "error building email")
+	}
+
+	recipients, err := e.recipients()
+	if err != nil {
+		return errors.Wrap(err, "error building email")
+	}
+
+	auth, err := e.auth()
+	if err != nil {
+		return errors.Wrap(err, "error sending email")
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error sending email")
+	}
+	defer c.Close()
 
-	// Set up authentication/address information
-	auth := smtp.PlainAuth("", e.From, e.Password, e.SMTP)
-	addr := fmt.Sprintf("%s:%s", e.SMTP, e.Port)
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return errors.Wrap(err, "error sending email")
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.From)
+	if err := c.Mail(from.Address); err != nil {
+		return errors.Wrap(err, "error sending email")
+	}
 
-	err := smtp.SendMail(addr, auth, e.From, e.To, formattedMsg)
+	for _, r := range recipients {
+		if err := c.Rcpt(r); err != nil {
+			return errors.Wrapf(err, "error sending email to %s", r)
+		}
+	}
+
+	w, err := c.Data()
 	if err != nil {
 		return errors.Wrap(err, "error sending email")
 	}
 
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "error sending email")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "error sending email")
+	}
+
+	c.Quit()
+
 	log.Println("alert email sent")
 
 	return nil
@@ -61,16 +409,34 @@ func (e Email) Valid() error {
 		errString = append(errString, ErrEmailNoSMTP.Error())
 	}
 
-	if len(e.To) < 1 {
+	if len(e.To)+len(e.CC)+len(e.BCC) < 1 {
 		errString = append(errString, ErrEmailNoTo.Error())
 	}
 
+	if _, err := e.recipients(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
 	if e.From == "" {
 		errString = append(errString, ErrEmailNoFrom.Error())
+	} else if _, err := mail.ParseAddress(e.From); err != nil {
+		errString = append(errString, errors.Wrap(err, "invalid email from address").Error())
+	}
+
+	switch strings.ToLower(e.Auth) {
+	case "", "none":
+	case "plain", "login", "cram-md5":
+		if e.Password == "" {
+			errString = append(errString, ErrEmailNoPass.Error())
+		}
+	default:
+		errString = append(errString, ErrEmailAuth.Error())
 	}
 
-	if e.Password == "" {
-		errString = append(errString, ErrEmailNoPass.Error())
+	switch e.encryption() {
+	case EmailOpportunistic, EmailSTARTTLS, EmailTLS, EmailNoEncryption:
+	default:
+		errString = append(errString, ErrEmailEncryption.Error())
 	}
 
 	if e.Port == "" {
@@ -81,6 +447,10 @@ func (e Email) Valid() error {
 		errString = append(errString, ErrEmailNoSubject.Error())
 	}
 
+	if _, err := e.htmlTemplate(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
 	if len(errString) == 0 {
 		return nil
 	}
@@ -91,9 +461,189 @@ func (e Email) Valid() error {
 	return errors.Wrap(err, "email settings validation fail")
 }
 
-// Slack contains all the info needed to connect to a slack channel
+// loginAuth implements the LOGIN authentication mechanism which is not part of net/smtp
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+// Start begins the LOGIN authentication, like PLAIN it requires an encrypted connection
+// unless the server is local
+func (l *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	local := server.Name == "localhost" || server.Name == "127.0.0.1" || server.Name == "::1"
+	if !server.TLS && !local {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != l.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+// Next answers the username and password prompts of the server
+func (l *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	prompt := strings.ToLower(string(fromServer))
+	switch {
+	case strings.Contains(prompt, "username"):
+		return []byte(l.username), nil
+	case strings.Contains(prompt, "password"):
+		return []byte(l.password), nil
+	default:
+		return nil, errors.Errorf("unexpected LOGIN prompt: %s", fromServer)
+	}
+}
+
+// Slack contains all the info needed to connect to a slack channel, the channel, username
+// and icon overrides are optional. With a bot token instead of a webhook, the first failure
+// of a check opens a message and the following alerts of the check are posted in its thread.
 type Slack struct {
-	WebhookURL string
+	WebhookURL    string
+	Token         string
+	APIURL        string
+	Channel       string
+	Username      string
+	IconEmoji     string
+	IconURL       string
+	ResolveParent bool
+	threads       *slackThreads
+}
+
+// slackThreads keeps the messages opened by the failures which have not recovered yet, and
+// the alerts already posted by the attempts of a list so that a retry does not post them again
+type slackThreads struct {
+	sync.Mutex
+	threads map[string]slackThread
+	posted  map[string]slackPosted
+}
+
+// slackPosted is the number of alerts of a list posted by its attempts
+type slackPosted struct {
+	first time.Time
+	count int
+}
+
+// slackThread is the message opened by a failure, the channel is the ID returned by slack
+type slackThread struct {
+	Channel    string
+	TS         string
+	Text       string
+	Attachment slackAttachment
+}
+
+// slackMessage is the payload of a slack message
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	TS          string            `json:"ts,omitempty"`
+	ThreadTS    string            `json:"thread_ts,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+// slackAttachment holds the blocks of an alert, its color shows the state of the alert
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a Block Kit block
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+// slackText is a Block Kit text object
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackColors are the colors of the attachments by alert state
+var slackColors = map[AlertState]string{
+	StateFailure:  "#d93f3c",
+	StateRecovery: "#2eb886",
+	StateInfo:     "#a0a0a0",
+}
+
+// slackEscape escapes the control characters of the slack mrkdwn format
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// slackMrkdwn returns a mrkdwn text object
+func slackMrkdwn(s string) slackText {
+	return slackText{Type: "mrkdwn", Text: s}
+}
+
+// Attachment returns the attachment of an alert, with its title and message and the
+// container, check and usage as fields
+func (s Slack) Attachment(a Alert) slackAttachment {
+	text := ""
+	if title := strings.TrimSpace(a.Title); title != "" {
+		text = "*" + slackEscape(title) + "*\n"
+	}
+	text += slackEscape(strings.TrimSpace(a.Message))
+	if a.Error != nil {
+		text += "\n" + slackEscape(a.Error.Error())
+	}
+
+	blocks := []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}}
+
+	fields := []slackText{}
+	if a.Container != "" {
+		fields = append(fields, slackMrkdwn("*Container*\n"+slackEscape(a.Container)))
+	}
+	if a.Check != "" {
+		fields = append(fields, slackMrkdwn("*Check*\n"+a.Check))
+	}
+	if a.Value != nil && a.Limit != nil {
+		fields = append(fields, slackMrkdwn(fmt.Sprintf("*Usage*\n%d", *a.Value)),
+			slackMrkdwn(fmt.Sprintf("*Limit*\n%d", *a.Limit)))
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields})
+	}
+
+	footer := []slackText{}
+	if a.Host != "" {
+		footer = append(footer, slackMrkdwn(slackEscape(a.Host)+" | "+a.Time.Format(time.RFC1123)))
+	}
+	if a.AckURL != "" {
+		footer = append(footer, slackMrkdwn("<"+a.AckURL+"|Acknowledge>"))
+	}
+	if len(footer) > 0 {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: footer})
+	}
+
+	return slackAttachment{Color: slackColors[a.State], Blocks: blocks}
+}
+
+// Message returns the message of the alerts, the text is the notification fallback
+func (s Slack) Message(a *AlertList) slackMessage {
+	msg := slackMessage{
+		Channel:   s.Channel,
+		Username:  s.Username,
+		IconEmoji: s.IconEmoji,
+		IconURL:   s.IconURL,
+		Text:      slackEscape(strings.TrimSpace(a.Title())),
+	}
+
+	for _, alert := range a.Alerts {
+		msg.Attachments = append(msg.Attachments, s.Attachment(alert))
+	}
+
+	return msg
 }
 
 // Valid returns an error if slack settings are invalid
@@ -104,7 +654,14 @@ func (s Slack) Valid() error {
 		return nil // assume that slack was omitted
 	}
 
-	if s.WebhookURL == "" {
+	switch {
+	case s.WebhookURL != "" && s.Token != "":
+		errString = append(errString, ErrSlackWebhookAndToken.Error())
+	case s.Token != "":
+		if s.Channel == "" {
+			errString = append(errString, ErrSlackNoChannel.Error())
+		}
+	case s.WebhookURL == "":
 		errString = append(errString, ErrSlackNoWebHookURL.Error())
 	}
 
@@ -119,96 +676,226 @@ func (s Slack) Valid() error {
 }
 
 // Alert sends the alert to a slack channel
-func (s Slack) Alert(a *AlertList) error {
-	alerts := a.Dump()
+func (s Slack) Alert(ctx context.Context, a *AlertList) error {
+	if s.Token != "" {
+		return s.alertThreads(ctx, a)
+	}
 
-	json := fmt.Sprintf("{\"text\": \"%s\"}", alerts)
-	body := bytes.NewReader([]byte(json))
-	resp, err := http.Post(s.WebhookURL, "application/json", body)
+	b, err := json.Marshal(s.Message(a))
 	if err != nil {
-		return err
+		return errors.Wrap(err, "error encoding slack message")
+	}
+
+	resp, err := httpPost(ctx, s.WebhookURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to slack")
 	}
 	defer resp.Body.Close()
 
+	// slack answers with a short explanation like "invalid_payload" on errors
+	if err := checkStatus("slack", resp); err != nil {
+		return err
+	}
+
 	log.Println("sent alert to slack")
 	return nil
 }
 
-// Pushover contains all info needed to push a notification to Pushover api
-type Pushover struct {
-	APIToken string
-	UserKey  string
-	APIURL   string
+// slackResponse is the response of the slack Web API
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
 }
 
-// Valid returns an error if pushover settings are invalid
-func (p Pushover) Valid() error {
-	errString := []string{}
+// call posts a message to a method of the slack Web API
+func (s Slack) call(ctx context.Context, method string, msg slackMessage) (slackResponse, error) {
+	var r slackResponse
 
-	if reflect.DeepEqual(Pushover{}, p) {
-		return nil // assume that pushover was omitted
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return r, errors.Wrap(err, "error encoding slack message")
 	}
 
-	if p.APIToken == "" {
-		errString = append(errString, ErrPushoverAPIToken.Error())
+	api := s.APIURL
+	if api == "" {
+		api = "https://slack.com/api"
 	}
 
-	if p.UserKey == "" {
-		errString = append(errString, ErrPushoverUserKey.Error())
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(api, "/")+"/"+method,
+		bytes.NewReader(b))
+	if err != nil {
+		return r, err
 	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.Token)
 
-	if p.APIURL == "" {
-		errString = append(errString, ErrPushoverAPIURL.Error())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return r, errors.Wrapf(err, "error calling slack %s", method)
 	}
+	defer resp.Body.Close()
 
-	if len(errString) == 0 {
-		return nil
+	if err := checkStatus("slack "+method, resp); err != nil {
+		return r, err
 	}
 
-	delimErr := strings.Join(errString, ", ")
-	err := errors.New(delimErr)
+	// the Web API answers 200 to failed calls, the error is in the body
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return r, errors.Wrapf(err, "error decoding slack %s response", method)
+	}
+	if !r.OK {
+		return r, errors.Errorf("slack %s failed: %s", method, r.Error)
+	}
 
-	return errors.Wrap(err, "pushover settings validation fail")
+	return r, nil
 }
 
-// Alert sends the alert to Pushover API
-func (p Pushover) Alert(a *AlertList) error {
-	alerts := a.Dump()
+// alertThreads posts every alert as a message. The first failure of a check opens a thread,
+// the reminders and the recovery are replies, and the parent message is marked as resolved
+// on recovery when ResolveParent is set.
+func (s Slack) alertThreads(ctx context.Context, a *AlertList) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "error encoding slack message")
+	}
+	key := fmt.Sprintf("%x", sha256.Sum256(b))
 
-	parsedBody := fmt.Sprintf("token=%s&user=%s&message=%s", p.APIToken, p.UserKey,
-		url.QueryEscape(alerts))
-	body := bytes.NewBufferString(parsedBody)
+	s.threads.Lock()
+	defer s.threads.Unlock()
 
-	resp, err := http.Post(p.APIURL, "application/x-www-form-urlencoded", body)
-	if err != nil {
-		return err
+	for k, p := range s.threads.posted {
+		if time.Since(p.first) > 24*time.Hour {
+			delete(s.threads.posted, k)
+		}
+	}
+	posted, ok := s.threads.posted[key]
+	if !ok {
+		posted = slackPosted{first: time.Now()}
 	}
-	defer resp.Body.Close()
 
-	log.Println("sent alert to pushover")
+	for i, alert := range a.Alerts {
+		thread, threaded := s.threads.threads[alert.Key()]
+
+		if i >= posted.count {
+			msg := slackMessage{
+				Channel:     s.Channel,
+				Username:    s.Username,
+				IconEmoji:   s.IconEmoji,
+				IconURL:     s.IconURL,
+				Text:        slackEscape(strings.TrimSpace(alert.Title)),
+				Attachments: []slackAttachment{s.Attachment(alert)},
+			}
+
+			if threaded {
+				msg.Channel = thread.Channel
+				msg.ThreadTS = thread.TS
+			}
+
+			r, err := s.call(ctx, "chat.postMessage", msg)
+			if err != nil {
+				return err
+			}
+
+			posted.count = i + 1
+			s.threads.posted[key] = posted
+
+			if alert.State == StateFailure && !threaded && alert.Check != "" {
+				s.threads.threads[alert.Key()] = slackThread{Channel: r.Channel, TS: r.TS,
+					Text: msg.Text, Attachment: msg.Attachments[0]}
+			}
+		}
+
+		// the thread is kept until the parent message is resolved, so that a retry of a
+		// recovery already posted only updates it
+		if alert.State != StateRecovery || !threaded {
+			continue
+		}
+
+		if s.ResolveParent {
+			thread.Attachment.Color = slackColors[StateRecovery]
+			_, err := s.call(ctx, "chat.update", slackMessage{
+				Channel:     thread.Channel,
+				TS:          thread.TS,
+				Text:        "RESOLVED: " + thread.Text,
+				Attachments: []slackAttachment{thread.Attachment},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		delete(s.threads.threads, alert.Key())
+	}
+
+	delete(s.threads.posted, key)
+
+	log.Println("sent alert to slack")
 	return nil
 }
 
-// Pushbullet contains all info needed to push a notification to Pushbullet api
-type Pushbullet struct {
-	AccessToken string
-	Title  string
+// SaveState returns the threads of the failures which have not recovered yet
+func (s Slack) SaveState() ([]byte, error) {
+	if s.threads == nil {
+		return nil, nil
+	}
+
+	s.threads.Lock()
+	defer s.threads.Unlock()
+
+	if len(s.threads.threads) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(s.threads.threads)
 }
 
-// Valid returns an error if Pushbullet settings are invalid
-func (p Pushbullet) Valid() error {
+// RestoreState restores the threads of the active failures, the replies and the recovery
+// of a failure opened before a restart are posted in its thread
+func (s Slack) RestoreState(b []byte, active map[string]bool) error {
+	threads := map[string]slackThread{}
+	if err := json.Unmarshal(b, &threads); err != nil {
+		return errors.Wrap(err, "error decoding the slack threads")
+	}
+
+	s.threads.Lock()
+	defer s.threads.Unlock()
+
+	for key, thread := range threads {
+		if active[key] {
+			s.threads.threads[key] = thread
+		}
+	}
+
+	return nil
+}
+
+// Matrix contains all info needed to send a notice to a Matrix room
+type Matrix struct {
+	HomeserverURL string
+	AccessToken   string
+	RoomID        string
+}
+
+// Valid returns an error if matrix settings are invalid
+func (m Matrix) Valid() error {
 	errString := []string{}
 
-	if reflect.DeepEqual(Pushbullet{}, p) {
-		return nil // assume that Pushbullet was omitted
+	if reflect.DeepEqual(Matrix{}, m) {
+		return nil // assume that matrix was omitted
 	}
 
-	if p.AccessToken == "" {
-		errString = append(errString, ErrPushbulletAccessToken.Error())
+	if m.HomeserverURL == "" {
+		errString = append(errString, ErrMatrixHomeserverURL.Error())
 	}
-	
-	if p.Title == "" {
-		errString = append(errString, ErrPushbulletTitle.Error())
+
+	if m.AccessToken == "" {
+		errString = append(errString, ErrMatrixAccessToken.Error())
+	}
+
+	if m.RoomID == "" {
+		errString = append(errString, ErrMatrixRoomID.Error())
 	}
 
 	if len(errString) == 0 {
@@ -218,19 +905,433 @@ func (p Pushbullet) Valid() error {
 	delimErr := strings.Join(errString, ", ")
 	err := errors.New(delimErr)
 
-	return errors.Wrap(err, "pushbullet settings validation fail")
+	return errors.Wrap(err, "matrix settings validation fail")
 }
 
-// Alert sends the alert to Pushbullet API
-func (p Pushbullet) Alert(a *AlertList) error {
-	json := fmt.Sprintf("{\"body\":\"%s\",\"title\":\"%s\",\"type\":\"note\"}", strings.Replace(a.Message(), "\n", "\\n", -1), strings.TrimSpace(p.Title + a.Title()))
+// Alert sends the alert to the matrix room as a m.notice message
+func (m Matrix) Alert(ctx context.Context, a *AlertList) error {
+	b, err := json.Marshal(map[string]string{
+		"msgtype":        "m.notice",
+		"body":           strings.TrimSpace(a.DumpEmail()),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": a.DumpHTML(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error encoding matrix message")
+	}
+
+	// the transaction id makes the request idempotent, it is the same for every attempt of a
+	// message so that a retry of a request which went through is not posted twice
+	txn, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "error encoding matrix message")
+	}
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/alertd-%x",
+		strings.TrimRight(m.HomeserverURL, "/"), url.PathEscape(m.RoomID), sha256.Sum256(txn))
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to matrix")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus("matrix", resp); err != nil {
+		return err
+	}
+
+	log.Println("sent alert to matrix")
+	return nil
+}
+
+// Mattermost contains all info needed to post to a Mattermost incoming webhook
+type Mattermost struct {
+	WebhookURL string
+	Channel    string
+	Username   string
+	IconURL    string
+	IconEmoji  string
+}
+
+// Valid returns an error if mattermost settings are invalid
+func (m Mattermost) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Mattermost{}, m) {
+		return nil // assume that mattermost was omitted
+	}
+
+	if m.WebhookURL == "" {
+		errString = append(errString, ErrMattermostNoWebHookURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "mattermost settings validation fail")
+}
+
+// Alert posts the alert to the mattermost webhook, the titles are in bold
+func (m Mattermost) Alert(ctx context.Context, a *AlertList) error {
+	text := []string{}
+	for _, alert := range a.Alerts {
+		s := strings.TrimSpace(alert.Message)
+		if title := strings.TrimSpace(alert.Title); title != "" {
+			s = "**" + title + "**\n" + s
+		}
+		if alert.Error != nil {
+			s += "\n" + alert.Error.Error()
+		}
+		text = append(text, s)
+	}
+
+	payload := map[string]string{"text": strings.Join(text, "\n\n")}
+	for k, v := range map[string]string{
+		"channel":    m.Channel,
+		"username":   m.Username,
+		"icon_url":   m.IconURL,
+		"icon_emoji": m.IconEmoji,
+	} {
+		if v != "" {
+			payload[k] = v
+		}
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "error encoding mattermost message")
+	}
+
+	resp, err := httpPost(ctx, m.WebhookURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to mattermost")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus("mattermost", resp); err != nil {
+		return err
+	}
+
+	log.Println("sent alert to mattermost")
+	return nil
+}
+
+// Pushover contains all info needed to push a notification to Pushover api. Failures are
+// sent at priority 1 and recoveries at -1, a failure priority of 2 makes them emergencies
+// which are repeated every Retry seconds until they are acknowledged, they expire or the
+// check recovers.
+type Pushover struct {
+	APIToken         string
+	UserKey          string
+	APIURL           string
+	Device           string
+	FailurePriority  *int
+	RecoveryPriority *int
+	Retry            int
+	Expire           int
+	Sounds           map[string]string
+	receipts         *pushoverReceipts
+}
+
+// pushoverReceipts keeps the receipts of the emergency notifications by alert key, they are
+// cancelled when the checks recover
+type pushoverReceipts struct {
+	sync.Mutex
+	receipts map[string]string
+}
+
+// pushoverResponse is the response of the Pushover api
+type pushoverResponse struct {
+	Status  int      `json:"status"`
+	Receipt string   `json:"receipt"`
+	Errors  []string `json:"errors"`
+}
+
+// pushover limits
+const (
+	pushoverEmergency = 2
+	pushoverMinRetry  = 30
+	pushoverMaxExpire = 10800
+)
+
+// Valid returns an error if pushover settings are invalid
+func (p Pushover) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Pushover{}, p) {
+		return nil // assume that pushover was omitted
+	}
+
+	if p.APIToken == "" {
+		errString = append(errString, ErrPushoverAPIToken.Error())
+	}
+
+	if p.UserKey == "" {
+		errString = append(errString, ErrPushoverUserKey.Error())
+	}
+
+	if p.APIURL == "" {
+		errString = append(errString, ErrPushoverAPIURL.Error())
+	}
+
+	for _, priority := range []int{intOr(p.FailurePriority, 1), intOr(p.RecoveryPriority, -1)} {
+		if priority < -2 || priority > pushoverEmergency {
+			errString = append(errString, ErrPushoverPriority.Error())
+			break
+		}
+	}
+
+	if p.Retry != 0 && p.Retry < pushoverMinRetry {
+		errString = append(errString, ErrPushoverRetry.Error())
+	}
+
+	if p.Expire < 0 || p.Expire > pushoverMaxExpire {
+		errString = append(errString, ErrPushoverExpire.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "pushover settings validation fail")
+}
+
+// sound returns the sound of the notification, set by check name for failures and by state
+// ("recovery" or "info") otherwise. An empty sound is the default of the user.
+func (p Pushover) sound(a *AlertList) string {
+	state := a.State()
+	if state != StateFailure {
+		return p.Sounds[string(state)]
+	}
+
+	for _, alert := range a.Alerts {
+		if alert.State == StateFailure {
+			if sound, ok := p.Sounds[alert.Check]; ok {
+				return sound
+			}
+		}
+	}
+
+	return p.Sounds[string(StateFailure)]
+}
+
+// Form returns the parameters of the notification
+func (p Pushover) Form(a *AlertList) url.Values {
+	priority := pushPriority(a, intOr(p.FailurePriority, 1), intOr(p.RecoveryPriority, -1), 0)
+
+	form := url.Values{
+		"token":    {p.APIToken},
+		"user":     {p.UserKey},
+		"title":    {strings.TrimSpace(a.Title())},
+		"message":  {a.Dump()},
+		"priority": {strconv.Itoa(priority)},
+	}
+
+	if p.Device != "" {
+		form.Set("device", p.Device)
+	}
+
+	if sound := p.sound(a); sound != "" {
+		form.Set("sound", sound)
+	}
+
+	if priority == pushoverEmergency {
+		retry, expire := p.Retry, p.Expire
+		if retry == 0 {
+			retry = 60
+		}
+		if expire == 0 {
+			expire = 3600
+		}
+
+		form.Set("retry", strconv.Itoa(retry))
+		form.Set("expire", strconv.Itoa(expire))
+	}
+
+	return form
+}
+
+// post posts a form to the Pushover api and returns its response
+func (p Pushover) post(ctx context.Context, endpoint string, form url.Values) (pushoverResponse, error) {
+	var r pushoverResponse
+
+	resp, err := httpPost(ctx, endpoint, "application/x-www-form-urlencoded",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	// the error details are in the body for 4xx responses
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil && resp.StatusCode/100 == 2 {
+		return r, errors.Wrap(err, "error decoding pushover response")
+	}
+
+	if resp.StatusCode/100 != 2 || r.Status != 1 {
+		return r, &StatusError{Service: "pushover", Code: resp.StatusCode, Status: resp.Status,
+			Body: strings.Join(r.Errors, ", ")}
+	}
+
+	return r, nil
+}
+
+// cancel cancels the emergency notifications of the recovered checks, and the replaced
+// ones, whose receipt is not shared with a failure which is still active. The failed
+// cancellations are logged, they do not fail the delivery of the message which was sent.
+func (p Pushover) cancel(ctx context.Context, a *AlertList, replaced []string) {
+	cancelled := map[string]bool{}
+	for _, receipt := range replaced {
+		cancelled[receipt] = true
+	}
+
+	for _, alert := range a.Alerts {
+		receipt, ok := p.receipts.receipts[alert.Key()]
+		if alert.State != StateRecovery || !ok {
+			continue
+		}
+
+		delete(p.receipts.receipts, alert.Key())
+		cancelled[receipt] = true
+	}
+
+	for _, receipt := range p.receipts.receipts {
+		delete(cancelled, receipt)
+	}
+
+	// receipts are cancelled on <api>/receipts/<receipt>/cancel.json, next to messages.json
+	base := p.APIURL[:strings.LastIndex(p.APIURL, "/")+1]
+
+	for receipt := range cancelled {
+		endpoint := base + "receipts/" + url.PathEscape(receipt) + "/cancel.json"
+		if _, err := p.post(ctx, endpoint, url.Values{"token": {p.APIToken}}); err != nil {
+			log.Println(errors.Wrap(err, "error cancelling pushover emergency"))
+		}
+	}
+}
+
+// SaveState returns the receipts of the emergency notifications which are not cancelled yet
+func (p Pushover) SaveState() ([]byte, error) {
+	if p.receipts == nil {
+		return nil, nil
+	}
+
+	p.receipts.Lock()
+	defer p.receipts.Unlock()
+
+	if len(p.receipts.receipts) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(p.receipts.receipts)
+}
+
+// RestoreState restores the receipts of the active failures, their emergency notifications
+// are cancelled when they recover after a restart
+func (p Pushover) RestoreState(b []byte, active map[string]bool) error {
+	receipts := map[string]string{}
+	if err := json.Unmarshal(b, &receipts); err != nil {
+		return errors.Wrap(err, "error decoding the pushover receipts")
+	}
+
+	p.receipts.Lock()
+	defer p.receipts.Unlock()
+
+	for key, receipt := range receipts {
+		if active[key] {
+			p.receipts.receipts[key] = receipt
+		}
+	}
+
+	return nil
+}
+
+// Alert sends the alert to Pushover API
+func (p Pushover) Alert(ctx context.Context, a *AlertList) error {
+	form := p.Form(a)
+
+	r, err := p.post(ctx, p.APIURL, form)
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to pushover")
+	}
+
+	if p.receipts != nil {
+		p.receipts.Lock()
+		defer p.receipts.Unlock()
+
+		replaced := []string{}
+		if r.Receipt != "" && form.Get("priority") == strconv.Itoa(pushoverEmergency) {
+			for _, alert := range a.Alerts {
+				if alert.State != StateFailure {
+					continue
+				}
+				if previous, ok := p.receipts.receipts[alert.Key()]; ok {
+					replaced = append(replaced, previous)
+				}
+				p.receipts.receipts[alert.Key()] = r.Receipt
+			}
+		}
+
+		p.cancel(ctx, a, replaced)
+	}
+
+	log.Println("sent alert to pushover")
+	return nil
+}
+
+// Pushbullet contains all info needed to push a notification to Pushbullet api
+type Pushbullet struct {
+	AccessToken string
+	Title  string
+}
+
+// Valid returns an error if Pushbullet settings are invalid
+func (p Pushbullet) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Pushbullet{}, p) {
+		return nil // assume that Pushbullet was omitted
+	}
+
+	if p.AccessToken == "" {
+		errString = append(errString, ErrPushbulletAccessToken.Error())
+	}
+	
+	if p.Title == "" {
+		errString = append(errString, ErrPushbulletTitle.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "pushbullet settings validation fail")
+}
+
+// Alert sends the alert to Pushbullet API
+func (p Pushbullet) Alert(ctx context.Context, a *AlertList) error {
+	json := fmt.Sprintf("{\"body\":\"%s\",\"title\":\"%s\",\"type\":\"note\"}", strings.Replace(a.Message(), "\n", "\\n", -1), strings.TrimSpace(p.Title + a.Title()))
 	//log.Println(json)
 	
 	body := bytes.NewReader([]byte(json))
 	
-	client := &http.Client{}
-	
-	req, err := http.NewRequest("POST", "https://api.pushbullet.com/v2/pushes", body)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.pushbullet.com/v2/pushes", body)
 	if err != nil {
 		return err
 	}
@@ -238,13 +1339,730 @@ func (p Pushbullet) Alert(a *AlertList) error {
 	req.Header.Add("Access-Token", p.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 	
-	resp, err := client.Do(req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
 	
 	defer resp.Body.Close()
 
+	if err := checkStatus("pushbullet", resp); err != nil {
+		return err
+	}
+
 	log.Println("sent alert to pushbullet")
 	return nil
-}
\ No newline at end of file
+}
+
+// Alertmanager contains all info needed to push alerts to a Prometheus Alertmanager
+type Alertmanager struct {
+	URL            string
+	RepeatInterval uint64
+	Labels         map[string]string
+	active         *alertmanagerActive
+}
+
+// alertmanagerActive keeps the failures which have not recovered yet, they are re-posted
+// periodically so that Alertmanager does not expire them
+type alertmanagerActive struct {
+	sync.Mutex
+	alerts map[string]alertmanagerAlert
+	once   sync.Once
+}
+
+// alertmanagerAlert is the format of an alert in the Alertmanager API v2
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Valid returns an error if alertmanager settings are invalid
+func (m Alertmanager) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Alertmanager{}, m) {
+		return nil // assume that alertmanager was omitted
+	}
+
+	if m.URL == "" {
+		errString = append(errString, ErrAlertmanagerURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "alertmanager settings validation fail")
+}
+
+// interval returns the duration between two posts of the active alerts
+func (m Alertmanager) interval() time.Duration {
+	if m.RepeatInterval == 0 {
+		return time.Minute
+	}
+
+	return time.Duration(m.RepeatInterval) * time.Second
+}
+
+// convert turns an alert into its Alertmanager representation
+func (m Alertmanager) convert(a Alert) alertmanagerAlert {
+	labels := map[string]string{}
+	for k, v := range m.Labels {
+		labels[k] = v
+	}
+
+	labels["alertname"] = "docker-alertd"
+	labels["container"] = a.Container
+	labels["check"] = a.Check
+	labels["host"] = a.Host
+	labels["severity"] = a.Severity
+
+	return alertmanagerAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     strings.TrimSpace(a.Title),
+			"description": strings.TrimSpace(a.Message),
+		},
+		StartsAt: a.Time,
+	}
+}
+
+// Alert sends the failures and recoveries of the list to Alertmanager, informational
+// alerts are skipped since they can not be resolved
+func (m Alertmanager) Alert(ctx context.Context, a *AlertList) error {
+	m.active.once.Do(func() {
+		go m.repeat()
+	})
+
+	alerts := []alertmanagerAlert{}
+
+	m.active.Lock()
+	for _, alert := range a.Alerts {
+		switch alert.State {
+		case StateFailure:
+			am := m.convert(alert)
+			m.active.alerts[alert.Key()] = am
+
+			am.EndsAt = time.Now().Add(4 * m.interval())
+			alerts = append(alerts, am)
+
+		case StateRecovery:
+			am := m.convert(alert)
+			if previous, ok := m.active.alerts[alert.Key()]; ok {
+				am.StartsAt = previous.StartsAt
+				delete(m.active.alerts, alert.Key())
+			}
+
+			am.EndsAt = alert.Time
+			alerts = append(alerts, am)
+		}
+	}
+	m.active.Unlock()
+
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	err := m.post(ctx, alerts)
+	if err != nil {
+		return err
+	}
+
+	log.Println("sent alert to alertmanager")
+	return nil
+}
+
+// repeat re-posts the active alerts with a refreshed end time until they recover
+func (m Alertmanager) repeat() {
+	for range time.Tick(m.interval()) {
+		alerts := []alertmanagerAlert{}
+
+		m.active.Lock()
+		for _, am := range m.active.alerts {
+			am.EndsAt = time.Now().Add(4 * m.interval())
+			alerts = append(alerts, am)
+		}
+		m.active.Unlock()
+
+		if len(alerts) == 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryDefaultTimeout*time.Second)
+		if err := m.post(ctx, alerts); err != nil {
+			log.Println(err)
+		}
+		cancel()
+	}
+}
+
+// SaveState returns the failures posted to Alertmanager which have not recovered yet
+func (m Alertmanager) SaveState() ([]byte, error) {
+	if m.active == nil {
+		return nil, nil
+	}
+
+	m.active.Lock()
+	defer m.active.Unlock()
+
+	if len(m.active.alerts) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(m.active.alerts)
+}
+
+// RestoreState restores the active failures, they are re-posted again until they recover so
+// that Alertmanager does not expire them after a restart
+func (m Alertmanager) RestoreState(b []byte, active map[string]bool) error {
+	alerts := map[string]alertmanagerAlert{}
+	if err := json.Unmarshal(b, &alerts); err != nil {
+		return errors.Wrap(err, "error decoding the alertmanager alerts")
+	}
+
+	m.active.Lock()
+	defer m.active.Unlock()
+
+	for key, am := range alerts {
+		if active[key] {
+			m.active.alerts[key] = am
+		}
+	}
+
+	if len(m.active.alerts) != 0 {
+		m.active.once.Do(func() {
+			go m.repeat()
+		})
+	}
+
+	return nil
+}
+
+// post sends the alerts to the Alertmanager API
+func (m Alertmanager) post(ctx context.Context, alerts []alertmanagerAlert) error {
+	b, err := json.Marshal(alerts)
+	if err != nil {
+		return errors.Wrap(err, "error encoding alertmanager alerts")
+	}
+
+	endpoint := strings.TrimRight(m.URL, "/") + "/api/v2/alerts"
+	resp, err := httpPost(ctx, endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to alertmanager")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus("alertmanager", resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Exec contains all info needed to run a command for each alert batch
+type Exec struct {
+	Command string
+	Args    []string
+	Timeout uint64
+}
+
+// Valid returns an error if exec settings are invalid
+func (e Exec) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Exec{}, e) {
+		return nil // assume that exec was omitted
+	}
+
+	if e.Command == "" {
+		errString = append(errString, ErrExecNoCommand.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "exec settings validation fail")
+}
+
+// Env returns the environment of the command, the ALERTD_ variables contain the distinct
+// values of the batch separated by commas
+func (e Exec) Env(a *AlertList) []string {
+	values := func(field func(Alert) string) string {
+		seen := map[string]bool{}
+		list := []string{}
+		for _, alert := range a.Alerts {
+			v := field(alert)
+			if v != "" && !seen[v] {
+				seen[v] = true
+				list = append(list, v)
+			}
+		}
+		return strings.Join(list, ",")
+	}
+
+	return append(os.Environ(),
+		"ALERTD_CONTAINER="+values(func(a Alert) string { return a.Container }),
+		"ALERTD_CHECK="+values(func(a Alert) string { return a.Check }),
+		"ALERTD_STATE="+values(func(a Alert) string { return string(a.State) }),
+		"ALERTD_SEVERITY="+values(func(a Alert) string { return a.Severity }),
+		"ALERTD_HOST="+values(func(a Alert) string { return a.Host }),
+		"ALERTD_TITLE="+strings.TrimSpace(a.Title()),
+		fmt.Sprintf("ALERTD_COUNT=%d", a.Len()),
+	)
+}
+
+// Alert runs the command with the alert list as JSON on its stdin
+func (e Exec) Alert(ctx context.Context, a *AlertList) error {
+	timeout := time.Duration(e.Timeout) * time.Second
+	if e.Timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	input, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "error encoding alerts for exec")
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+	cmd.Env = e.Env(a)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // do not wait for children still holding stderr
+
+	err = cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return errors.Errorf("exec %s timed out after %s: %s", e.Command, timeout,
+			strings.TrimSpace(stderr.String()))
+	case err != nil:
+		return errors.Wrapf(err, "error running exec %s: %s", e.Command,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	log.Println("ran alert command", e.Command)
+	return nil
+}
+
+// pushPriority returns the priority of an alert batch according to its state
+func pushPriority(a *AlertList, failure, recovery, info int) int {
+	switch a.State() {
+	case StateFailure:
+		return failure
+	case StateRecovery:
+		return recovery
+	default:
+		return info
+	}
+}
+
+// intOr returns the value of the pointer or the default value when it is nil
+func intOr(p *int, d int) int {
+	if p == nil {
+		return d
+	}
+
+	return *p
+}
+
+// Gotify contains all info needed to push a notification to a Gotify server
+type Gotify struct {
+	URL              string
+	Token            string
+	FailurePriority  *int
+	RecoveryPriority *int
+}
+
+// Valid returns an error if Gotify settings are invalid
+func (g Gotify) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Gotify{}, g) {
+		return nil // assume that Gotify was omitted
+	}
+
+	if g.URL == "" {
+		errString = append(errString, ErrGotifyURL.Error())
+	}
+
+	if g.Token == "" {
+		errString = append(errString, ErrGotifyToken.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "gotify settings validation fail")
+}
+
+// Alert sends the alert to the Gotify message API
+func (g Gotify) Alert(ctx context.Context, a *AlertList) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"title":    strings.TrimSpace(a.Title()),
+		"message":  strings.TrimSpace(a.Message()),
+		"priority": pushPriority(a, intOr(g.FailurePriority, 8), intOr(g.RecoveryPriority, 4), 2),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error encoding gotify message")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(g.URL, "/")+"/message",
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Gotify-Key", g.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to gotify")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus("gotify", resp); err != nil {
+		return err
+	}
+
+	log.Println("sent alert to gotify")
+	return nil
+}
+
+// Ntfy contains all info needed to publish a notification to a ntfy topic
+type Ntfy struct {
+	URL              string
+	Topic            string
+	Token            string
+	FailurePriority  *int
+	RecoveryPriority *int
+}
+
+// Valid returns an error if ntfy settings are invalid
+func (n Ntfy) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Ntfy{}, n) {
+		return nil // assume that ntfy was omitted
+	}
+
+	if n.Topic == "" {
+		errString = append(errString, ErrNtfyTopic.Error())
+	}
+
+	for _, p := range []*int{n.FailurePriority, n.RecoveryPriority} {
+		if p != nil && (*p < 1 || *p > 5) {
+			errString = append(errString, ErrNtfyPriority.Error())
+			break
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "ntfy settings validation fail")
+}
+
+// Alert publishes the alert to the ntfy topic, the server defaults to https://ntfy.sh
+func (n Ntfy) Alert(ctx context.Context, a *AlertList) error {
+	server := n.URL
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+
+	endpoint := strings.TrimRight(server, "/") + "/" + url.PathEscape(n.Topic)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint,
+		strings.NewReader(strings.TrimSpace(a.Message())))
+	if err != nil {
+		return err
+	}
+
+	priority := pushPriority(a, intOr(n.FailurePriority, 4), intOr(n.RecoveryPriority, 3), 2)
+
+	tags := map[AlertState]string{
+		StateFailure:  "rotating_light",
+		StateRecovery: "white_check_mark",
+		StateInfo:     "information_source",
+	}[a.State()]
+
+	// the Title header must be ASCII, the UTF-8 title is sent RFC 2047 encoded
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", strings.TrimSpace(a.Title())))
+	req.Header.Set("Priority", fmt.Sprintf("%d", priority))
+	req.Header.Set("Tags", tags)
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending alert to ntfy")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus("ntfy", resp); err != nil {
+		return err
+	}
+
+	log.Println("sent alert to ntfy")
+	return nil
+}
+
+// SMS contains all info needed to send text messages with the Twilio Messages API, the base
+// URL can point to any compatible service
+type SMS struct {
+	BaseURL    string
+	AccountSID string
+	AuthToken  string
+	From       string
+	To         []string
+	MaxLength  int
+	sent       *smsDeliveries
+}
+
+// smsDeliveries keeps the recipients which received a message, so that the retries of the
+// message are only sent to the recipients which failed. The messages are forgotten once
+// every recipient received them, or after a day.
+type smsDeliveries struct {
+	sync.Mutex
+	messages map[string]smsDelivery
+}
+
+// smsDelivery is the recipients which received a message
+type smsDelivery struct {
+	first time.Time
+	to    map[string]bool
+}
+
+// Valid returns an error if SMS settings are invalid
+func (s SMS) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(SMS{}, s) {
+		return nil // assume that SMS was omitted
+	}
+
+	if s.AccountSID == "" {
+		errString = append(errString, ErrSMSAccountSID.Error())
+	}
+
+	if s.AuthToken == "" {
+		errString = append(errString, ErrSMSAuthToken.Error())
+	}
+
+	if s.From == "" {
+		errString = append(errString, ErrSMSFrom.Error())
+	}
+
+	if len(s.To) < 1 {
+		errString = append(errString, ErrSMSTo.Error())
+	}
+
+	if s.MaxLength < 0 || s.MaxLength > 1600 {
+		errString = append(errString, ErrSMSMaxLength.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "sms settings validation fail")
+}
+
+// Summary condenses the alerts into a single text of at most MaxLength (default 160)
+// characters, made of the host and the titles of the alerts. The titles which do not fit
+// are counted at the end of the text.
+func (s SMS) Summary(a *AlertList) string {
+	limit := s.MaxLength
+	if limit == 0 {
+		limit = 160
+	}
+
+	parts := []string{}
+	for _, alert := range a.Alerts {
+		title := strings.TrimSpace(alert.Title)
+		if title == "" {
+			title = strings.TrimSpace(strings.SplitN(alert.Message, "\n", 2)[0])
+		}
+		parts = append(parts, title)
+	}
+
+	prefix := ""
+	if len(a.Alerts) > 0 && a.Alerts[0].Host != "" {
+		prefix = a.Alerts[0].Host + ": "
+	}
+
+	length := func(s string) int { return len([]rune(s)) }
+
+	// titles returns the titles fitting in limit characters
+	titles := func(limit int) string {
+		text := ""
+		for i, part := range parts {
+			if i > 0 {
+				part = "; " + part
+			}
+
+			// keep room to count the titles which would not fit after this one
+			suffix := ""
+			if rest := len(parts) - i - 1; rest > 0 {
+				suffix = fmt.Sprintf(" (+%d more)", rest)
+			}
+
+			if length(text+part+suffix) <= limit {
+				text += part
+				continue
+			}
+
+			if i > 0 {
+				return text + fmt.Sprintf(" (+%d more)", len(parts)-i)
+			}
+
+			// even the first title is too long, it is truncated
+			n := limit - length(suffix) - 3
+			if n < 0 {
+				n = 0
+			}
+			if n > length(part) {
+				n = length(part)
+			}
+			return strings.TrimSpace(string([]rune(part)[:n])) + "..." + suffix
+		}
+
+		return text
+	}
+
+	// the host is left out when the limit is too small for it
+	text := prefix + titles(limit-length(prefix))
+	if length(text) > limit {
+		text = titles(limit)
+	}
+	if length(text) > limit {
+		text = string([]rune(text)[:limit])
+	}
+
+	return text
+}
+
+// Alert sends the summary of the alerts to every recipient, the error is permanent when the
+// recipients which failed were all rejected by twilio
+func (s SMS) Alert(ctx context.Context, a *AlertList) error {
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.twilio.com"
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(baseURL, "/"),
+		url.PathEscape(s.AccountSID))
+	summary := s.Summary(a)
+
+	// the attempts of the same message are identified by its alerts
+	var sent map[string]bool
+	if s.sent != nil {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return errors.Wrap(err, "error encoding sms")
+		}
+		key := fmt.Sprintf("%x", sha256.Sum256(b))
+
+		s.sent.Lock()
+		for k, m := range s.sent.messages {
+			if time.Since(m.first) > 24*time.Hour {
+				delete(s.sent.messages, k)
+			}
+		}
+		if _, ok := s.sent.messages[key]; !ok {
+			s.sent.messages[key] = smsDelivery{first: time.Now(), to: map[string]bool{}}
+		}
+		sent = s.sent.messages[key].to
+		s.sent.Unlock()
+
+		defer func() {
+			s.sent.Lock()
+			if len(sent) == len(s.To) {
+				delete(s.sent.messages, key)
+			}
+			s.sent.Unlock()
+		}()
+	}
+
+	errString := []string{}
+	retry, rejected, rejectedTo := false, error(nil), []string{}
+
+	for _, to := range s.To {
+		if sent != nil {
+			s.sent.Lock()
+			done := sent[to]
+			s.sent.Unlock()
+			if done {
+				continue
+			}
+		}
+
+		form := url.Values{"To": {to}, "From": {s.From}, "Body": {summary}}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+
+		req.SetBasicAuth(s.AccountSID, s.AuthToken)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			err = checkStatus("twilio", resp)
+			resp.Body.Close()
+		}
+
+		if err != nil {
+			errString = append(errString, fmt.Sprintf("%s: %s", to, err))
+			if temporary(err) {
+				retry = true
+				continue
+			}
+
+			// the recipients rejected by twilio are not retried
+			rejected, rejectedTo = err, append(rejectedTo, to)
+		}
+
+		if sent != nil {
+			s.sent.Lock()
+			sent[to] = true
+			s.sent.Unlock()
+		}
+	}
+
+	if len(errString) != 0 && !retry {
+		return errors.Wrapf(rejected, "error sending sms to %s", strings.Join(rejectedTo, ", "))
+	}
+	if len(errString) != 0 {
+		return errors.Wrap(errors.New(strings.Join(errString, ", ")), "error sending sms")
+	}
+
+	log.Println("sent alert by sms")
+	return nil
+}
@@ -0,0 +1,865 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestAlertmanagerAlert(t *testing.T) {
+	var posted [][]alertmanagerAlert
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		var alerts []alertmanagerAlert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, alerts)
+	}))
+	defer srv.Close()
+
+	m := Alertmanager{
+		URL:            srv.URL,
+		RepeatInterval: 3600,
+		Labels:         map[string]string{"team": "ops"},
+		active:         &alertmanagerActive{alerts: map[string]alertmanagerAlert{}},
+	}
+
+	start := time.Now().Add(-time.Minute)
+
+	failure := &AlertList{}
+	failure.AddAlert(Alert{Title: "CPU check failure", Message: "web: CPU limit: 20", Container: "web",
+		Check: CheckCPU, State: StateFailure, Severity: "critical", Host: "host1", Time: start})
+	failure.Add("Starting", "Starting", nil)
+
+	if err := m.Alert(context.Background(), failure); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posted) != 1 || len(posted[0]) != 1 {
+		t.Fatalf("expected one alert to be posted, got %v", posted)
+	}
+
+	got := posted[0][0]
+	for k, v := range map[string]string{"container": "web", "check": CheckCPU, "host": "host1",
+		"severity": "critical", "team": "ops"} {
+		if got.Labels[k] != v {
+			t.Errorf("label %s: expected %s, got %s", k, v, got.Labels[k])
+		}
+	}
+
+	if got.Annotations["summary"] != "CPU check failure" {
+		t.Errorf("unexpected summary: %s", got.Annotations["summary"])
+	}
+
+	if !got.EndsAt.After(time.Now()) {
+		t.Errorf("active alert should end in the future, got %s", got.EndsAt)
+	}
+
+	if len(m.active.alerts) != 1 {
+		t.Errorf("expected the failure to be kept as active")
+	}
+
+	recovery := &AlertList{}
+	recovery.AddAlert(Alert{Title: "CPU check recovered", Container: "web", Check: CheckCPU,
+		State: StateRecovery, Severity: "critical", Host: "host1"})
+
+	if err := m.Alert(context.Background(), recovery); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posted) != 2 {
+		t.Fatalf("expected the recovery to be posted")
+	}
+
+	got = posted[1][0]
+	if !got.StartsAt.Equal(start) {
+		t.Errorf("recovery should keep the start of the failure, got %s", got.StartsAt)
+	}
+
+	if got.EndsAt.After(time.Now()) {
+		t.Errorf("recovery should end now, got %s", got.EndsAt)
+	}
+
+	if len(m.active.alerts) != 0 {
+		t.Errorf("expected the recovered alert to be removed")
+	}
+}
+
+func TestSyslogAlert(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := Syslog{Network: "udp", Address: conn.LocalAddr().String(), Facility: "local0", Tag: "alertd"}
+	if err := s.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", Message: "web \"front\"\nCPU limit: 20",
+		Container: `web "front"`, Check: CheckCPU, State: StateFailure, Severity: "warning",
+		Host: "host1"})
+
+	if err := s.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+
+	// local0 (16) * 8 + warning (4)
+	if !strings.HasPrefix(msg, "<132>1 ") {
+		t.Errorf("unexpected priority: %s", msg)
+	}
+
+	for _, part := range []string{" host1 alertd ", " cpu [alertd@32473 container=\"web \\\"front\\\"\" check=\"cpu\" state=\"failure\" severity=\"warning\"] ",
+		"CPU check failure - web \"front\" CPU limit: 20"} {
+		if !strings.Contains(msg, part) {
+			t.Errorf("expected %q in message: %s", part, msg)
+		}
+	}
+}
+
+func TestJournaldAlert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "Memory recovery", Message: "usage: 10\nlimit: 20", Container: "db",
+		Check: CheckMemory, State: StateRecovery, Severity: "critical"})
+
+	if err := (Journald{Socket: socket}).Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := string(buf[:n])
+
+	for _, field := range []string{"MESSAGE=Memory recovery - usage: 10 limit: 20\n", "PRIORITY=5\n",
+		"SYSLOG_IDENTIFIER=docker-alertd\n", "ALERTD_CONTAINER=db\n", "ALERTD_CHECK=memory\n",
+		"ALERTD_STATE=recovery\n"} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("expected %q in entry: %q", field, entry)
+		}
+	}
+
+	// a journald block left at its defaults enables journald
+	c := &Conf{}
+	viper.Set("journald", map[string]interface{}{})
+	defer viper.Reset()
+	if err := c.ValidateJournaldSettings(); err != nil || len(c.Alerters) != 1 {
+		t.Errorf("expected journald to be enabled, got %v %+v", err, c.Alerters)
+	}
+}
+
+func TestExecAlert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", Container: "web", Check: CheckCPU, State: StateFailure})
+	a.AddAlert(Alert{Title: "Memory check failure", Container: "web", Check: CheckMemory,
+		State: StateFailure, Error: ErrUnknown})
+
+	e := Exec{
+		Command: "/bin/sh",
+		Args:    []string{"-c", `cat > "$0"; echo >> "$0"; echo "$ALERTD_CONTAINER|$ALERTD_CHECK|$ALERTD_STATE" >> "$0"`, out},
+	}
+	if err := e.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.SplitN(string(b), "\n", 2)
+	if lines[1] != "web|cpu,memory|failure\n" {
+		t.Errorf("unexpected environment: %q", lines[1])
+	}
+
+	var got struct {
+		Alerts []map[string]interface{} `json:"alerts"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Alerts) != 2 || got.Alerts[1]["error"] != ErrUnknown.Error() {
+		t.Errorf("unexpected stdin: %s", lines[0])
+	}
+
+	e = Exec{Command: "/bin/sh", Args: []string{"-c", "echo broken >&2; exit 3"}}
+	if err := e.Alert(context.Background(), a); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the error to contain stderr, got %v", err)
+	}
+
+	e = Exec{Command: "/bin/sh", Args: []string{"-c", "sleep 5"}, Timeout: 1}
+	if err := e.Alert(context.Background(), a); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestPushAlerters(t *testing.T) {
+	var req *http.Request
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	failure := &AlertList{}
+	failure.AddAlert(Alert{Title: "CPU check failure", Message: "web: CPU limit: 20", State: StateFailure})
+	failure.AddAlert(Alert{Title: "Memory check recovered", Message: "db", State: StateRecovery})
+
+	recovery := &AlertList{}
+	recovery.AddAlert(Alert{Title: "Memory check recovered", Message: "db", State: StateRecovery})
+
+	g := Gotify{URL: srv.URL, Token: "apptoken"}
+	if err := g.Alert(context.Background(), failure); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg struct {
+		Title    string
+		Message  string
+		Priority int
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.URL.Path != "/message" || req.Header.Get("X-Gotify-Key") != "apptoken" {
+		t.Errorf("unexpected gotify request: %s %v", req.URL.Path, req.Header)
+	}
+	if msg.Priority != 8 || msg.Title != "CPU check failure Memory check recovered" {
+		t.Errorf("unexpected gotify message: %+v", msg)
+	}
+
+	n := Ntfy{URL: srv.URL, Topic: "alerts", RecoveryPriority: func(i int) *int { return &i }(1)}
+	if err := n.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Alert(context.Background(), recovery); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.URL.Path != "/alerts" || req.Header.Get("Priority") != "1" ||
+		req.Header.Get("Title") != "Memory check recovered" || string(body) != "db" {
+		t.Errorf("unexpected ntfy request: %s %v %s", req.URL.Path, req.Header, body)
+	}
+
+	if err := (Ntfy{Topic: "alerts", FailurePriority: func(i int) *int { return &i }(7)}).Valid(); err == nil {
+		t.Errorf("expected an invalid priority error")
+	}
+}
+
+func TestChatAlerters(t *testing.T) {
+	var req *http.Request
+	var body map[string]string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body = map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", Message: "<web>\nCPU limit: 20", State: StateFailure})
+
+	m := Matrix{HomeserverURL: srv.URL, AccessToken: "token", RoomID: "!room:example.com"}
+	if err := m.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "PUT" || !strings.HasPrefix(req.URL.Path, "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/") ||
+		req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("unexpected matrix request: %s %s %v", req.Method, req.URL.Path, req.Header)
+	}
+	if body["msgtype"] != "m.notice" ||
+		body["formatted_body"] != "<strong>CPU check failure</strong><br>&lt;web&gt;<br>CPU limit: 20" {
+		t.Errorf("unexpected matrix message: %v", body)
+	}
+
+	// the retries of a message have the same transaction id, the next message another one
+	path := req.URL.Path
+	if err := m.Alert(context.Background(), a); err != nil || req.URL.Path != path {
+		t.Errorf("expected the same transaction id for a retry, got %s and %s", path, req.URL.Path)
+	}
+	b := &AlertList{}
+	b.AddAlert(Alert{Title: "CPU check failure", Message: "<web>\nCPU limit: 20", State: StateFailure})
+	if err := m.Alert(context.Background(), b); err != nil || req.URL.Path == path {
+		t.Errorf("expected another transaction id for the next message, got %s", req.URL.Path)
+	}
+
+	mm := Mattermost{WebhookURL: srv.URL, Channel: "alerts", Username: "alertd"}
+	if err := mm.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	if body["text"] != "**CPU check failure**\n<web>\nCPU limit: 20" || body["channel"] != "alerts" ||
+		body["username"] != "alertd" {
+		t.Errorf("unexpected mattermost message: %v", body)
+	}
+	if _, ok := body["icon_url"]; ok {
+		t.Errorf("empty overrides should be omitted")
+	}
+}
+
+func TestSMSSummary(t *testing.T) {
+	a := &AlertList{}
+	for _, title := range []string{"CPU check failure", "Memory check failure", "Running check failure"} {
+		a.AddAlert(Alert{Title: title + " ", Host: "host1", State: StateFailure})
+	}
+
+	tests := []struct {
+		MaxLength int
+		Expected  string
+	}{
+		{0, "host1: CPU check failure; Memory check failure; Running check failure"},
+		{60, "host1: CPU check failure; Memory check failure (+1 more)"},
+		{40, "host1: CPU check failure (+2 more)"},
+		{30, "host1: CPU check... (+2 more)"},
+		{20, "host1: ... (+2 more)"},
+		{15, "CP... (+2 more)"},
+	}
+
+	for _, test := range tests {
+		got := SMS{MaxLength: test.MaxLength}.Summary(a)
+		if got != test.Expected {
+			t.Errorf("max length %d: expected %q, got %q", test.MaxLength, test.Expected, got)
+		}
+		if test.MaxLength != 0 && len(got) > test.MaxLength {
+			t.Errorf("max length %d: summary is %d characters long", test.MaxLength, len(got))
+		}
+	}
+}
+
+func TestSMSAlert(t *testing.T) {
+	var recipients []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" || user != "AC123" || pass != "secret" {
+			t.Errorf("unexpected request: %s %s:%s", r.URL.Path, user, pass)
+		}
+
+		recipients = append(recipients, r.FormValue("To"))
+		switch r.FormValue("To") {
+		case "+2":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case "+3":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", State: StateFailure})
+
+	s := SMS{BaseURL: srv.URL, AccountSID: "AC123", AuthToken: "secret", From: "+0", To: []string{"+1", "+2", "+3"}}
+	err := s.Alert(context.Background(), a)
+	if err == nil || !strings.Contains(err.Error(), "+2: twilio responded with status 400") || !temporary(err) {
+		t.Errorf("expected the failed recipients in a temporary error, got %v", err)
+	}
+
+	if strings.Join(recipients, ",") != "+1,+2,+3" {
+		t.Errorf("every recipient should be tried, got %v", recipients)
+	}
+
+	// the retries are only sent to the recipients which failed temporarily
+	s.sent = &smsDeliveries{messages: map[string]smsDelivery{}}
+	for i := 0; i < 2; i++ {
+		recipients = nil
+		s.Alert(context.Background(), a)
+	}
+	if strings.Join(recipients, ",") != "+3" {
+		t.Errorf("expected the retry to be sent to +3 only, got %v", recipients)
+	}
+
+	// a message rejected by every remaining recipient is not retried
+	s.To, s.sent = []string{"+1", "+2"}, nil
+	if err := s.Alert(context.Background(), a); err == nil || temporary(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	e := Email{
+		From:    "Alertd <auto@example.com>",
+		To:      []string{"jeff@example.com", "Zoë <zoe@example.com>"},
+		CC:      []string{"ops@example.com"},
+		BCC:       []string{"hidden@example.com"},
+		Subject:   "DOCKER_ALERTD",
+		PlainText: true,
+	}
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "Memory failure", Message: "usage: 25 MB — limit: 20 MB", State: StateFailure})
+
+	date := time.Date(2017, 9, 18, 12, 11, 44, 0, time.UTC)
+	msg, err := e.Message(a, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, _ := dec.DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != "DOCKER_ALERTD Memory failure" {
+		t.Errorf("unexpected subject: %q", subject)
+	}
+
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[1].Name != "Zoë" {
+		t.Errorf("unexpected To header: %q (%v)", parsed.Header.Get("To"), err)
+	}
+
+	for h, v := range map[string]string{
+		"From":         `"Alertd" <auto@example.com>`,
+		"Cc":           "<ops@example.com>",
+		"Bcc":          "",
+		"Date":         "Mon, 18 Sep 2017 12:11:44 +0000",
+		"Content-Type": "text/plain; charset=UTF-8",
+	} {
+		if parsed.Header.Get(h) != v {
+			t.Errorf("header %s: expected %q, got %q", h, v, parsed.Header.Get(h))
+		}
+	}
+
+	if !strings.HasSuffix(parsed.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("unexpected Message-ID: %s", parsed.Header.Get("Message-ID"))
+	}
+
+	body, _ := ioutil.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if !strings.Contains(string(body), "usage: 25 MB — limit: 20 MB") {
+		t.Errorf("unexpected body: %q", body)
+	}
+}
+
+func TestEmailHTMLMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	custom := filepath.Join(dir, "email.html")
+	ioutil.WriteFile(custom, []byte(`{{range .Alerts}}<p>{{.Container}} {{.State}}</p>{{end}}`), 0644)
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", Message: "<web>", Container: "web", Check: CheckCPU,
+		State: StateFailure, Since: time.Now().Add(-90 * time.Second), Value: uint64P(35), Limit: uint64P(20)})
+	a.AddAlert(Alert{Title: "Running check recovered", Container: "db", Check: CheckRunning,
+		State: StateRecovery})
+
+	tests := []struct {
+		Template string
+		Expected []string
+	}{
+		{"", []string{"#f8d7da", "#d4edda", "<td>35 / 20</td>", "<td>1m30s</td>", "&lt;web&gt;"}},
+		{custom, []string{"<p>web failure</p><p>db recovery</p>"}},
+	}
+
+	for _, test := range tests {
+		e := Email{From: "auto@example.com", To: []string{"jeff@example.com"}, HTMLTemplate: test.Template}
+
+		msg, err := e.Message(a, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts := []string{}
+		r := multipart.NewReader(parsed.Body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := ioutil.ReadAll(p) // the reader decodes quoted-printable
+			parts = append(parts, p.Header.Get("Content-Type")+"\n"+string(b))
+		}
+
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "text/plain") || !strings.HasPrefix(parts[1], "text/html") {
+			t.Fatalf("expected a plain text and a html part, got %q", parts)
+		}
+
+		for _, e := range test.Expected {
+			if !strings.Contains(parts[1], e) {
+				t.Errorf("expected %q in the html part:\n%s", e, parts[1])
+			}
+		}
+	}
+}
+
+func TestEmailAlert(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	commands := make(chan []string, 1)
+
+	// a SMTP server without STARTTLS which only supports the LOGIN authentication
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := textproto.NewConn(conn)
+		r.PrintfLine("220 localhost ESMTP")
+
+		received := []string{}
+		for {
+			line, err := r.ReadLine()
+			if err != nil {
+				break
+			}
+			received = append(received, line)
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				r.PrintfLine("250-localhost\r\n250 AUTH LOGIN")
+			case line == "AUTH LOGIN":
+				r.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				user, _ := r.ReadLine()
+				r.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				pass, _ := r.ReadLine()
+				received = append(received, user, pass)
+				r.PrintfLine("235 ok")
+			case line == "DATA":
+				r.PrintfLine("354 go ahead")
+				r.ReadDotLines()
+				r.PrintfLine("250 queued")
+			case line == "QUIT":
+				r.PrintfLine("221 bye")
+				commands <- received
+				return
+			default:
+				r.PrintfLine("250 ok")
+			}
+		}
+		commands <- received
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	e := Email{
+		SMTP:     host,
+		Port:     port,
+		Auth:     "login",
+		Username: "alertd",
+		Password: "secret",
+		From:     "auto@example.com",
+		To:       []string{"jeff@example.com"},
+		BCC:      []string{"Hidden <hidden@example.com>"},
+		Subject:  "DOCKER_ALERTD",
+	}
+	if err := e.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &AlertList{}
+	a.Add("Starting", "Starting", nil)
+
+	if err := e.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	received := strings.Join(<-commands, "\n")
+	for _, c := range []string{
+		base64.StdEncoding.EncodeToString([]byte("alertd")),
+		base64.StdEncoding.EncodeToString([]byte("secret")),
+		"MAIL FROM:<auto@example.com>",
+		"RCPT TO:<jeff@example.com>",
+		"RCPT TO:<hidden@example.com>",
+	} {
+		if !strings.Contains(received, c) {
+			t.Errorf("expected %q in the session:\n%s", c, received)
+		}
+	}
+}
+
+func TestSlackAlert(t *testing.T) {
+	var msg slackMessage
+	status := http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_payload"))
+	}))
+	defer srv.Close()
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: `CPU "check" failure`, Message: "web\\front <1>", Container: "web",
+		Check: CheckCPU, State: StateFailure, Host: "host1", Value: uint64P(35), Limit: uint64P(20)})
+	a.AddAlert(Alert{Title: "Running check recovered", Container: "db", Check: CheckRunning,
+		State: StateRecovery})
+
+	s := Slack{WebhookURL: srv.URL, Channel: "#alerts"}
+	if err := s.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Channel != "#alerts" || msg.Username != "" || len(msg.Attachments) != 2 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	failure := msg.Attachments[0]
+	if failure.Color != slackColors[StateFailure] || msg.Attachments[1].Color != slackColors[StateRecovery] {
+		t.Errorf("unexpected colors: %s %s", failure.Color, msg.Attachments[1].Color)
+	}
+
+	if failure.Blocks[0].Text.Text != "*CPU \"check\" failure*\nweb\\front &lt;1&gt;" {
+		t.Errorf("unexpected text: %q", failure.Blocks[0].Text.Text)
+	}
+
+	fields := []string{}
+	for _, f := range failure.Blocks[1].Fields {
+		fields = append(fields, f.Text)
+	}
+	if strings.Join(fields, "|") != "*Container*\nweb|*Check*\ncpu|*Usage*\n35|*Limit*\n20" {
+		t.Errorf("unexpected fields: %q", fields)
+	}
+
+	status = http.StatusBadRequest
+	if err := s.Alert(context.Background(), a); err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("expected the slack error, got %v", err)
+	}
+}
+
+func TestSlackThreads(t *testing.T) {
+	type call struct {
+		Method string
+		Msg    slackMessage
+	}
+	calls := []call{}
+	failing := ""
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-token" {
+			t.Errorf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		}
+
+		var msg slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		calls = append(calls, call{strings.TrimPrefix(r.URL.Path, "/"), msg})
+
+		if msg.Text == failing {
+			failing = ""
+			fmt.Fprint(w, `{"ok": false, "error": "ratelimited"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok": true, "channel": "C123", "ts": "%d.000"}`, len(calls))
+	}))
+	defer srv.Close()
+
+	s := Slack{Token: "xoxb-token", APIURL: srv.URL, Channel: "#alerts", ResolveParent: true,
+		threads: &slackThreads{threads: map[string]slackThread{}, posted: map[string]slackPosted{}}}
+	if err := s.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []AlertState{StateFailure, StateFailure, StateRecovery} {
+		a := &AlertList{}
+		a.AddAlert(Alert{Title: "CPU check " + string(state), Container: "web", Check: CheckCPU,
+			State: state})
+		if err := s.Alert(context.Background(), a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []struct {
+		Method, Channel, TS, ThreadTS, Text string
+	}{
+		{"chat.postMessage", "#alerts", "", "", "CPU check failure"},
+		{"chat.postMessage", "C123", "", "1.000", "CPU check failure"},
+		{"chat.postMessage", "C123", "", "1.000", "CPU check recovery"},
+		{"chat.update", "C123", "1.000", "", "RESOLVED: CPU check failure"},
+	}
+
+	if len(calls) != len(expected) {
+		t.Fatalf("expected %d calls, got %+v", len(expected), calls)
+	}
+
+	for i, e := range expected {
+		c := calls[i]
+		if c.Method != e.Method || c.Msg.Channel != e.Channel || c.Msg.TS != e.TS ||
+			c.Msg.ThreadTS != e.ThreadTS || c.Msg.Text != e.Text {
+			t.Errorf("call %d: expected %+v, got %s %+v", i, e, c.Method, c.Msg)
+		}
+	}
+
+	if color := calls[3].Msg.Attachments[0].Color; color != slackColors[StateRecovery] {
+		t.Errorf("expected the parent message to be colored as recovered, got %s", color)
+	}
+
+	if len(s.threads.threads) != 0 {
+		t.Errorf("expected the thread to be closed, got %+v", s.threads.threads)
+	}
+
+	// the retry of a list only posts the alerts which were not posted
+	calls, failing = nil, "Memory check failure"
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", Container: "web", Check: CheckCPU, State: StateFailure})
+	a.AddAlert(Alert{Title: "Memory check failure", Container: "web", Check: CheckMemory,
+		State: StateFailure})
+	if err := s.Alert(context.Background(), a); err == nil {
+		t.Fatal("expected the failed message to fail the delivery")
+	}
+	if err := s.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[2].Msg.Text != "Memory check failure" {
+		t.Errorf("expected the retry to post the memory failure only, got %+v", calls)
+	}
+}
+
+func TestPushoverEmergency(t *testing.T) {
+	forms := []url.Values{}
+	paths := []string{}
+	cancelDown := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		forms = append(forms, r.PostForm)
+		paths = append(paths, r.URL.Path)
+
+		if cancelDown && strings.HasSuffix(r.URL.Path, "/cancel.json") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": 1, "receipt": "r1"}`))
+	}))
+	defer srv.Close()
+
+	emergency := 2
+	p := Pushover{APIToken: "token", UserKey: "user", APIURL: srv.URL + "/1/messages.json",
+		Device: "phone", FailurePriority: &emergency, Retry: 120,
+		Sounds: map[string]string{CheckCPU: "siren", "recovery": "magic"},
+		receipts: &pushoverReceipts{receipts: map[string]string{}}}
+	if err := p.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []AlertState{StateFailure, StateRecovery} {
+		a := &AlertList{}
+		a.AddAlert(Alert{Title: "CPU check " + string(state), Container: "web", Check: CheckCPU,
+			State: state})
+		if err := p.Alert(context.Background(), a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if strings.Join(paths, " ") != "/1/messages.json /1/messages.json /1/receipts/r1/cancel.json" {
+		t.Fatalf("unexpected requests: %v", paths)
+	}
+
+	failure := forms[0]
+	for key, value := range map[string]string{"title": "CPU check failure", "priority": "2",
+		"retry": "120", "expire": "3600", "sound": "siren", "device": "phone"} {
+		if failure.Get(key) != value {
+			t.Errorf("expected %s %q, got %q", key, value, failure.Get(key))
+		}
+	}
+
+	recovery := forms[1]
+	if recovery.Get("priority") != "-1" || recovery.Get("sound") != "magic" || recovery.Get("retry") != "" {
+		t.Errorf("unexpected recovery: %v", recovery)
+	}
+
+	if forms[2].Get("token") != "token" || len(p.receipts.receipts) != 0 {
+		t.Errorf("unexpected cancellation: %v, receipts left %v", forms[2], p.receipts.receipts)
+	}
+
+	// a failed cancellation does not fail the delivery of the recovery, which is not retried
+	cancelDown = true
+	for _, state := range []AlertState{StateFailure, StateRecovery} {
+		a := &AlertList{}
+		a.AddAlert(Alert{Title: "CPU check " + string(state), Container: "web", Check: CheckCPU,
+			State: state})
+		if err := p.Alert(context.Background(), a); err != nil {
+			t.Errorf("expected the %s to be delivered, got %v", state, err)
+		}
+	}
+}
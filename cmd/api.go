@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// API contains the settings of the HTTP API, it listens on Address when set and the requests
+// must carry the Token as a bearer token. URL is the external address of the API, used in
+// the signed acknowledgement links of the alerts.
+type API struct {
+	Address string
+	Token   string
+	URL     string
+}
+
+// Valid returns an error if api settings are invalid, the API is never served without a
+// token
+func (api API) Valid() error {
+	if api.Address != "" && api.Token == "" {
+		return errors.Wrap(ErrAPIToken, "api settings validation fail")
+	}
+
+	return nil
+}
+
+// Serve starts the API in the background
+func (api API) Serve(c *Conf) {
+	server := &http.Server{Addr: api.Address, Handler: api.Handler(c)}
+
+	go func() {
+		log.Printf("api listening on %s\n", api.Address)
+		if err := server.ListenAndServe(); err != nil {
+			log.Println(errors.Wrap(err, "api error"))
+		}
+	}()
+}
+
+// Handler returns the handler of the API routes
+func (api API) Handler(c *Conf) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/silences", api.auth(func(w http.ResponseWriter, r *http.Request) {
+		silencesHandler(c, w, r)
+	}))
+	mux.HandleFunc("/api/silences/", api.auth(func(w http.ResponseWriter, r *http.Request) {
+		silenceHandler(c, w, r)
+	}))
+	mux.HandleFunc("/api/alerts", api.auth(alertsHandler))
+	mux.HandleFunc("/api/alerts/ack", api.auth(func(w http.ResponseWriter, r *http.Request) {
+		ackHandler(c, w, r)
+	}))
+	// the acknowledgement links are authenticated by their signature
+	mux.HandleFunc("/api/ack", func(w http.ResponseWriter, r *http.Request) {
+		api.ackLinkHandler(c, w, r)
+	})
+
+	return mux
+}
+
+// auth rejects the requests without the token, every request is rejected if there is none
+func (api API) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if api.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(api.Token)) != 1 {
+			apiError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// apiError writes an error response
+func apiError(w http.ResponseWriter, code int, err error) {
+	apiJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// apiJSON writes a JSON response
+func apiJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// silencesHandler lists the silences on GET and adds an ad-hoc silence on POST
+func silencesHandler(c *Conf, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		silences, err := loadSilences(c.Silences.Path)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, err)
+			return
+		}
+		apiJSON(w, http.StatusOK, append(c.Silences.Static, silences...))
+
+	case http.MethodPost:
+		var s Silence
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			apiError(w, http.StatusBadRequest, errors.Wrap(err, "invalid silence"))
+			return
+		}
+
+		// only the static silences of the configuration file are recurring
+		s.Schedule = ""
+
+		s, err := AddSilence(c.Silences.Path, s)
+		switch {
+		case err == ErrSilencesPath:
+			apiError(w, http.StatusNotImplemented, err)
+		case err != nil:
+			apiError(w, http.StatusBadRequest, err)
+		default:
+			log.Printf("silence %s added: %s\n", s.ID, s.Comment)
+			apiJSON(w, http.StatusCreated, s)
+		}
+
+	default:
+		apiError(w, http.StatusMethodNotAllowed, errors.New(r.Method))
+	}
+}
+
+// silenceHandler removes an ad-hoc silence on DELETE
+func silenceHandler(c *Conf, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, http.StatusMethodNotAllowed, errors.New(r.Method))
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/silences/")
+	err := RemoveSilence(c.Silences.Path, id)
+	switch {
+	case errors.Cause(err) == ErrSilenceNotFound:
+		apiError(w, http.StatusNotFound, err)
+	case err == ErrSilencesPath:
+		apiError(w, http.StatusNotImplemented, err)
+	case err != nil:
+		apiError(w, http.StatusInternalServerError, err)
+	default:
+		log.Printf("silence %s removed\n", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// alertsHandler lists the active alerts on GET
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, http.StatusMethodNotAllowed, errors.New(r.Method))
+		return
+	}
+
+	apiJSON(w, http.StatusOK, activeAlerts.List())
+}
+
+// ackRequest is the body of an acknowledgement request
+type ackRequest struct {
+	Container string `json:"container"`
+	Check     string `json:"check"`
+	By        string `json:"by"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// ackError writes the error of an acknowledgement
+func ackError(w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case ErrAckNotActive:
+		apiError(w, http.StatusNotFound, err)
+	case ErrAckAlready:
+		apiError(w, http.StatusConflict, err)
+	default:
+		apiError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// ackHandler acknowledges an active alert on POST
+func ackHandler(c *Conf, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, http.StatusMethodNotAllowed, errors.New(r.Method))
+		return
+	}
+
+	var req ackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apiError(w, http.StatusBadRequest, errors.Wrap(err, "invalid acknowledgement"))
+		return
+	}
+
+	if req.By == "" {
+		req.By = r.RemoteAddr
+	}
+
+	ack := Acknowledgement{By: req.By, Comment: req.Comment}
+	if err := AcknowledgeAlert(c, req.Container, req.Check, time.Time{}, ack); err != nil {
+		ackError(w, err)
+		return
+	}
+
+	apiJSON(w, http.StatusOK, activeAlerts.Acknowledged(req.Container+"/"+req.Check))
+}
+
+// ackPage is the confirmation page of the acknowledgement links, the alert is only
+// acknowledged when the form is submitted so that the link previews and the security
+// scanners opening the links do not acknowledge it
+var ackPage = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Acknowledge {{.Container}} {{.Check}}</title></head>
+<body>
+<form method="post">
+<p>Acknowledge the {{.Check}} failure of {{.Container}}?</p>
+<p><label>By <input name="by" required></label></p>
+<p><label>Comment <input name="comment"></label></p>
+<p><button type="submit">Acknowledge</button></p>
+</form>
+</body>
+</html>
+`))
+
+// ackLinkHandler shows the confirmation page of the signed link of a failure on GET and
+// acknowledges the alert on POST, the signature is checked for both
+func (api API) ackLinkHandler(c *Conf, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		apiError(w, http.StatusMethodNotAllowed, errors.New(r.Method))
+		return
+	}
+
+	q := r.URL.Query()
+	container, check := q.Get("container"), q.Get("check")
+
+	since, err := strconv.ParseInt(q.Get("since"), 10, 64)
+	signature := ackSignature(api.Token, container, check, since)
+	if err != nil || api.Token == "" || !hmac.Equal([]byte(signature), []byte(q.Get("sig"))) {
+		apiError(w, http.StatusForbidden, errors.New("invalid signature"))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		ackPage.Execute(w, struct{ Container, Check string }{container, check})
+		return
+	}
+
+	by := r.PostFormValue("by")
+	if by == "" {
+		by = "link from " + r.RemoteAddr
+	}
+
+	ack := Acknowledgement{By: by, Comment: r.PostFormValue("comment")}
+	if err := AcknowledgeAlert(c, container, check, time.Unix(0, since), ack); err != nil {
+		ackError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s %s acknowledged\n", container, check)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cronSchedule is a parsed 5 fields cron expression: minute, hour, day of month, month and
+// day of week (0 or 7 is sunday). Each field accepts *, values, ranges, lists and steps,
+// like "*/15 1-5 * * 1,3".
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// restricted day fields, when both are the day matches either of them
+	domAll, dowAll bool
+}
+
+// cronFields are the bounds of the cron fields
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a cron expression
+func parseCron(s string) (cronSchedule, error) {
+	var c cronSchedule
+
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return c, errors.Errorf("invalid schedule %q: expected 5 fields", s)
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return c, errors.Wrapf(err, "invalid %s in schedule %q", cronFields[i].name, s)
+		}
+		bits[i] = b
+	}
+
+	c.minute, c.hour, c.dom, c.month, c.dow = bits[0], bits[1], bits[2], bits[3], bits[4]
+	c.domAll, c.dowAll = fields[2] == "*", fields[4] == "*"
+
+	// sunday is 0 or 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parseCronField returns the bits of the values of a field
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return 0, errors.Errorf("invalid step %q", part[i+1:])
+			}
+			step, part = s, part[:i]
+		}
+
+		start, end := min, max
+		switch i := strings.Index(part, "-"); {
+		case part == "*":
+		case i != -1:
+			var err error
+			if start, err = strconv.Atoi(part[:i]); err != nil {
+				return 0, errors.Errorf("invalid value %q", part[:i])
+			}
+			if end, err = strconv.Atoi(part[i+1:]); err != nil {
+				return 0, errors.Errorf("invalid value %q", part[i+1:])
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, errors.Errorf("invalid value %q", part)
+			}
+			start, end = v, v
+			if step != 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, errors.Errorf("%s out of range %d-%d", part, min, max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// Match returns true if the schedule fires at the minute of t
+func (c cronSchedule) Match(t time.Time) bool {
+	has := func(bits uint64, v int) bool { return bits&(1<<uint(v)) != 0 }
+
+	day := has(c.dom, t.Day()) && has(c.dow, int(t.Weekday()))
+	if !c.domAll && !c.dowAll {
+		day = has(c.dom, t.Day()) || has(c.dow, int(t.Weekday()))
+	}
+
+	return day && has(c.minute, t.Minute()) && has(c.hour, t.Hour()) &&
+		has(c.month, int(t.Month()))
+}
+
+// Active returns true if a window of the given duration started by the schedule contains t
+func (c cronSchedule) Active(t time.Time, d time.Duration) bool {
+	start := t.Truncate(time.Minute)
+	for m := start; t.Sub(m) < d; m = m.Add(-time.Minute) {
+		if c.Match(m) {
+			return true
+		}
+	}
+
+	return false
+}
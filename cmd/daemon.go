@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// default daemon settings
+const daemonDefaultTimeout = 5
+
+// Daemon contains the settings of the health check of the docker daemon, it is pinged
+// before each iteration and is down when it does not answer within Timeout seconds. The
+// container checks are paused while it is down.
+type Daemon struct {
+	Timeout uint64
+}
+
+// DaemonData is the data of the daemon templates
+type DaemonData struct {
+	Error    string
+	Version  string
+	Latency  time.Duration
+	Downtime time.Duration
+}
+
+// daemonCheck is the state of the health check of the docker daemon
+type daemonCheck struct {
+	settings  Daemon
+	templates *TemplateConfig
+	connect   func() (*client.Client, error)
+	cli       *client.Client
+	down      bool
+	since     time.Time
+	version   string
+	latency   time.Duration
+}
+
+// Start returns the health check of the daemon, the client is connected by the first check
+func (d Daemon) Start(t *TemplateConfig) *daemonCheck {
+	return &daemonCheck{settings: d, templates: t, connect: client.NewEnvClient}
+}
+
+// ping connects to the daemon if needed and pings it, the version is fetched on each
+// connection
+func (d *daemonCheck) ping() error {
+	timeout := time.Duration(d.settings.Timeout) * time.Second
+	if d.settings.Timeout == 0 {
+		timeout = daemonDefaultTimeout * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if d.cli == nil {
+		cli, err := d.connect()
+		if err != nil {
+			return errors.Wrap(err, "error creating the docker client")
+		}
+
+		v, err := cli.ServerVersion(ctx)
+		if err != nil {
+			cli.Close()
+			return err
+		}
+
+		d.cli, d.version = cli, v.Version
+	}
+
+	start := time.Now()
+	if _, err := d.cli.Ping(ctx); err != nil {
+		// the next check reconnects
+		d.cli.Close()
+		d.cli = nil
+		return err
+	}
+	d.latency = time.Since(start)
+
+	return nil
+}
+
+// Check pings the daemon and adds an alert when it goes down or comes back, it returns true
+// if the containers can be checked
+func (d *daemonCheck) Check(a *AlertList) bool {
+	err := d.ping()
+
+	switch {
+	case err != nil && !d.down:
+		d.down, d.since = true, time.Now()
+		log.Println(errors.Wrap(err, "docker daemon unreachable"))
+		d.addAlert(a, StateFailure, DaemonData{Error: err.Error(), Version: d.version})
+
+	case err == nil && d.down:
+		d.down = false
+		log.Printf("docker daemon %s reachable, latency %s\n", d.version, d.latency)
+		d.addAlert(a, StateRecovery, DaemonData{Version: d.version, Latency: d.latency,
+			Downtime: time.Since(d.since).Round(time.Second)})
+	}
+
+	return !d.down
+}
+
+// addAlert adds the alert of the daemon check
+func (d *daemonCheck) addAlert(a *AlertList, state AlertState, data DaemonData) {
+	var message bytes.Buffer
+	var title bytes.Buffer
+
+	d.templates.Executor.ExecuteTemplate(&message, fmt.Sprintf("daemon-%s-message", state), data)
+	d.templates.Executor.ExecuteTemplate(&title, fmt.Sprintf("daemon-%s-title", state), data)
+
+	alert := Alert{
+		Message:  message.String(),
+		Title:    title.String(),
+		Check:    CheckDaemon,
+		State:    state,
+		Severity: DefaultSeverity,
+		Since:    d.since,
+	}
+
+	// the daemon failure can be acknowledged, reminded and escalated like the others
+	switch state {
+	case StateFailure:
+		activeAlerts.Activate(alert)
+	case StateRecovery:
+		activeAlerts.Resolve(alert.Key())
+	}
+
+	a.AddAlert(alert)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestDaemonCheck(t *testing.T) {
+	defer func(r *alertRegistry) { activeAlerts = r }(activeAlerts)
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+	var down int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case atomic.LoadInt32(&down) == 1:
+			http.Error(w, "daemon stopped", http.StatusInternalServerError)
+		case strings.HasSuffix(r.URL.Path, "/version"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Version": "24.0.7"}`))
+		default:
+			w.Write([]byte("OK"))
+		}
+	}))
+	defer server.Close()
+
+	templates, err := TemplateConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connections := 0
+	d := Daemon{Timeout: 1}.Start(&templates)
+	d.connect = func() (*client.Client, error) {
+		connections++
+		return client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.25", nil, nil)
+	}
+
+	check := func(reachable bool, titles string) {
+		t.Helper()
+
+		a := &AlertList{}
+		if d.Check(a) != reachable {
+			t.Errorf("expected the daemon reachable: %v", reachable)
+		}
+
+		got := []string{}
+		for _, alert := range a.Alerts {
+			got = append(got, alert.Title)
+		}
+		if strings.Join(got, ",") != titles {
+			t.Errorf("expected alerts %q, got %q", titles, strings.Join(got, ","))
+		}
+	}
+
+	check(true, "")
+	if d.version != "24.0.7" {
+		t.Errorf("expected the daemon version, got %q", d.version)
+	}
+
+	// a single alert while the daemon is down
+	atomic.StoreInt32(&down, 1)
+	check(false, ErrDaemonCheckFail.Error())
+	check(false, "")
+	if len(activeAlerts.List()) != 1 {
+		t.Error("expected the daemon failure to be active")
+	}
+
+	atomic.StoreInt32(&down, 0)
+	check(true, ErrDaemonCheckRecovered.Error())
+	if connections < 2 {
+		t.Error("expected the client to reconnect")
+	}
+}
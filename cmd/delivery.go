@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// default delivery settings
+const (
+	deliveryDefaultRetries    = 3
+	deliveryDefaultBackoff    = 2
+	deliveryDefaultMaxBackoff = 60
+	deliveryDefaultTimeout    = 30
+)
+
+// sleep waits between two attempts unless the context is done first, it is replaced in tests
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliveries tracks the alerts being sent without a queue, so that they can be waited for
+// on shutdown
+var deliveries sync.WaitGroup
+
+// Delivery contains the retry policy of the alerters. A failed delivery is retried with an
+// exponential backoff, from Backoff to MaxBackoff seconds, and each attempt is limited to
+// Timeout seconds, which can be set by alerter in Timeouts (e.g. email: 120). The alerters
+// with a rate limit drop the deliveries over their limit, or pass them to their fallback.
+type Delivery struct {
+	Retries    *int
+	Backoff    uint64
+	MaxBackoff uint64
+	Timeout    uint64
+	Timeouts   map[string]uint64
+	RateLimits map[string]RateLimit
+	buckets    map[string]*tokenBucket
+	history    *historyStore
+}
+
+// errRateLimited is returned for the deliveries over the rate limit of an alerter
+var errRateLimited = errors.New("rate limit exceeded")
+
+// Valid returns an error if delivery settings are invalid
+func (d Delivery) Valid() error {
+	errString := []string{}
+
+	if intOr(d.Retries, deliveryDefaultRetries) < 0 {
+		errString = append(errString, ErrDeliveryRetries.Error())
+	}
+
+	if d.Backoff != 0 && d.MaxBackoff != 0 && d.MaxBackoff < d.Backoff {
+		errString = append(errString, ErrDeliveryBackoff.Error())
+	}
+
+	for name, limit := range d.RateLimits {
+		if limit.Rate <= 0 {
+			errString = append(errString, errors.Wrap(ErrDeliveryRateLimit, name).Error())
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "delivery settings validation fail")
+}
+
+// alerterName returns the name of an alerter, as used in the configuration file
+func alerterName(b Alerter) string {
+	t := reflect.TypeOf(b)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return strings.ToLower(t.Name())
+}
+
+// WithRateLimits returns the settings with the token buckets of the rate limits
+func (d Delivery) WithRateLimits() Delivery {
+	d.buckets = map[string]*tokenBucket{}
+	for name, limit := range d.RateLimits {
+		d.buckets[name] = newTokenBucket(limit)
+	}
+
+	return d
+}
+
+// timeout returns the duration of an attempt for the alerter
+func (d Delivery) timeout(name string) time.Duration {
+	if t, ok := d.Timeouts[name]; ok && t != 0 {
+		return time.Duration(t) * time.Second
+	}
+
+	if d.Timeout != 0 {
+		return time.Duration(d.Timeout) * time.Second
+	}
+
+	return deliveryDefaultTimeout * time.Second
+}
+
+// backoff returns the wait before the retry following the given attempt (starting at 0), it
+// doubles at each attempt and is randomized between half and the full value so that the
+// alerters do not retry in lockstep
+func (d Delivery) backoff(attempt int) time.Duration {
+	base, max := d.Backoff, d.MaxBackoff
+	if base == 0 {
+		base = deliveryDefaultBackoff
+	}
+	if max == 0 {
+		max = deliveryDefaultMaxBackoff
+	}
+	if max < base {
+		max = base
+	}
+
+	wait := time.Duration(max) * time.Second
+	if attempt < 32 && base<<uint(attempt) < max {
+		wait = time.Duration(base<<uint(attempt)) * time.Second
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retry sends the alerts with the alerter until it succeeds, fails with a permanent error,
+// every retry has failed or the context is done
+func (d Delivery) retry(ctx context.Context, b Alerter, a *AlertList) error {
+	name := alerterName(b)
+	retries := intOr(d.Retries, deliveryDefaultRetries)
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, d.timeout(name))
+		err := b.Alert(attemptCtx, a)
+		cancel()
+
+		if err == nil || attempt >= retries || !temporary(err) {
+			return err
+		}
+
+		wait := d.backoff(attempt)
+		log.Printf("error sending alert to %s (attempt %d/%d), retrying in %s: %s\n", name,
+			attempt+1, retries+1, wait.Round(time.Millisecond), err)
+
+		if sleep(ctx, wait) != nil {
+			return err
+		}
+	}
+}
+
+// retryChain sends the alerts with the first alerter of the chain which succeeds, the
+// fallbacks receive a copy of the alerts noting the failed delivery. The error is the one
+// of the last alerter.
+func (d Delivery) retryChain(ctx context.Context, chain AlerterChain, a *AlertList) error {
+	list := a
+
+	for i, b := range chain {
+		err := errRateLimited
+		if bucket, ok := d.buckets[alerterName(b)]; !ok || bucket.Allow() {
+			err = d.retry(ctx, b, list)
+		}
+
+		if err == nil || ctx.Err() != nil || i == len(chain)-1 {
+			return err
+		}
+
+		failed, next := alerterName(b), alerterName(chain[i+1])
+		log.Printf("error sending alert to %s, falling back to %s: %s\n", failed, next, err)
+
+		list = a.asFallback(next, failed, err)
+	}
+
+	return nil
+}
+
+// Deliver sends the alerts with the chain of alerters, retrying on temporary errors, and
+// logs the dropped alerts once every attempt has failed. The outcome is recorded in the
+// history.
+func (d Delivery) Deliver(ctx context.Context, chain AlerterChain, a *AlertList) error {
+	err := d.retryChain(ctx, chain, a)
+	if err != nil {
+		logDropped(chain.Name(), err, a)
+	}
+	d.history.Delivered(chain.Name(), a, err)
+
+	return err
+}
+
+// logDropped logs the alerts which will not be delivered with the last error
+func logDropped(name string, err error, a *AlertList) {
+	log.Printf("%s\n%s", errors.Wrapf(err, "dropped alert for %s", name), a.Dump())
+}
+
+// StatusError is returned by the HTTP alerters when the service does not answer with a 2xx
+// status, the body usually explains why the request was rejected
+type StatusError struct {
+	Service string
+	Code    int
+	Status  string
+	Body    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s responded with status %s", e.Service, e.Status)
+	}
+
+	return fmt.Sprintf("%s responded with status %s: %s", e.Service, e.Status, e.Body)
+}
+
+// Temporary returns true if the request can succeed later: server errors, timeouts and
+// rate limiting
+func (e *StatusError) Temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout ||
+		e.Code == http.StatusTooManyRequests
+}
+
+// checkStatus returns a StatusError if the response status is not 2xx
+func checkStatus(service string, resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+
+	return &StatusError{
+		Service: service,
+		Code:    resp.StatusCode,
+		Status:  resp.Status,
+		Body:    strings.TrimSpace(string(body)),
+	}
+}
+
+// httpPost posts the body to the url, the request is cancelled with the context
+func httpPost(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	return http.DefaultClient.Do(req)
+}
+
+// temporary returns false for the errors which will not go away by retrying: 4xx responses
+// and permanent SMTP failures, everything else (network errors, timeouts) is retried
+func temporary(err error) bool {
+	if err == errRateLimited {
+		return false
+	}
+
+	switch e := errors.Cause(err).(type) {
+	case *StatusError:
+		return e.Temporary()
+	case *textproto.Error:
+		return e.Code < 500
+	default:
+		return true
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestDeliver(t *testing.T) {
+	waits := []time.Duration{}
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	statuses := []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}
+	requests := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[requests])
+		requests++
+	}))
+	defer srv.Close()
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", State: StateFailure})
+
+	d := Delivery{Backoff: 2, MaxBackoff: 3}
+	if err := d.Deliver(context.Background(), AlerterChain{Gotify{URL: srv.URL, Token: "token"}}, a); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 3 || len(waits) != 2 {
+		t.Fatalf("expected 3 attempts and 2 waits, got %d and %v", requests, waits)
+	}
+
+	// the backoff doubles up to the max, with a jitter of half its value
+	if waits[0] < time.Second || waits[0] > 2*time.Second || waits[1] < 1500*time.Millisecond ||
+		waits[1] > 3*time.Second {
+		t.Errorf("unexpected backoff: %v", waits)
+	}
+
+	// client errors are not retried
+	requests, statuses = 0, []int{http.StatusUnauthorized}
+	err := d.Deliver(context.Background(), AlerterChain{Gotify{URL: srv.URL, Token: "token"}}, a)
+	if requests != 1 || err == nil {
+		t.Errorf("expected a single failed attempt, got %d: %v", requests, err)
+	}
+	if _, ok := errors.Cause(err).(*StatusError); !ok {
+		t.Errorf("expected a status error, got %T", errors.Cause(err))
+	}
+}
+
+// blockingAlerter waits for the end of the delivery attempt
+type blockingAlerter struct{}
+
+func (b blockingAlerter) Valid() error { return nil }
+
+func (b blockingAlerter) Alert(ctx context.Context, a *AlertList) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDeliverTimeout(t *testing.T) {
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+	sleep = func(context.Context, time.Duration) error { return nil }
+
+	retries := 1
+	d := Delivery{Retries: &retries, Timeout: 60, Timeouts: map[string]uint64{"blockingalerter": 1}}
+
+	start := time.Now()
+	err := d.Deliver(context.Background(), AlerterChain{blockingAlerter{}}, &AlertList{})
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 2*time.Second || elapsed > 10*time.Second {
+		t.Errorf("expected 2 attempts of a second, took %s", elapsed)
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	tests := []struct {
+		Err       error
+		Temporary bool
+	}{
+		{&StatusError{Code: http.StatusTooManyRequests}, true},
+		{&StatusError{Code: http.StatusInternalServerError}, true},
+		{errors.Wrap(&StatusError{Code: http.StatusForbidden}, "error"), false},
+		{&textproto.Error{Code: 421}, true},
+		{&textproto.Error{Code: 550}, false},
+		{errors.New("connection refused"), true},
+	}
+
+	for _, test := range tests {
+		if temporary(test.Err) != test.Temporary {
+			t.Errorf("%v: expected temporary %t", test.Err, test.Temporary)
+		}
+	}
+}
+
+func TestDeliverRateLimit(t *testing.T) {
+	retries := 0
+	d := Delivery{Retries: &retries, RateLimits: map[string]RateLimit{"recordingalerter": {Rate: 1, Burst: 2}}}
+	if err := d.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	d = d.WithRateLimits()
+
+	limited, fallback := &recordingAlerter{}, &syslogRecorder{}
+
+	for _, title := range []string{"first", "second", "third"} {
+		a := &AlertList{}
+		a.AddAlert(Alert{Title: title, State: StateFailure})
+		if err := d.Deliver(context.Background(), AlerterChain{limited, fallback}, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if limited.titles() != "first,second" || fallback.titles() != "third" {
+		t.Errorf("expected the third alert to fall back, got %q and %q", limited.titles(),
+			fallback.titles())
+	}
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "fourth", State: StateFailure})
+	if err := d.Deliver(context.Background(), AlerterChain{limited}, a); err != errRateLimited {
+		t.Errorf("expected the rate limit error, got %v", err)
+	}
+}
+
+// syslogRecorder is a recordingAlerter with another name, to be used as a fallback
+type syslogRecorder struct {
+	recordingAlerter
+}
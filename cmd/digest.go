@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the keys by which the alerts can be grouped in a digest
+const (
+	GroupByHost      = "host"
+	GroupByProject   = "project"
+	GroupByContainer = "container"
+	GroupByCheck     = "check"
+)
+
+// composeProjectLabel is the label set by docker compose on the containers of a project
+const composeProjectLabel = "com.docker.compose.project"
+
+// Digest contains the settings of the grouping window of the alerts. The failures and
+// recoveries are held until no alert has been added to their group for Wait seconds, or
+// MaxWait seconds (default Wait) after the first one, and each group is sent as a single
+// delivery. The informational alerts are not held.
+type Digest struct {
+	Wait    uint64
+	MaxWait uint64
+	GroupBy []string
+}
+
+// Valid returns an error if digest settings are invalid
+func (d Digest) Valid() error {
+	errString := []string{}
+
+	if d.MaxWait != 0 && d.MaxWait < d.Wait {
+		errString = append(errString, ErrDigestMaxWait.Error())
+	}
+
+	for _, key := range d.GroupBy {
+		switch key {
+		case GroupByHost, GroupByProject, GroupByContainer, GroupByCheck:
+		default:
+			errString = append(errString, errors.Wrap(ErrDigestGroupBy, key).Error())
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "digest settings validation fail")
+}
+
+// digestGroup is a list of alerts waiting for the end of its window
+type digestGroup struct {
+	list     *AlertList
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// digester holds the alerts by group until their window ends
+type digester struct {
+	sync.Mutex
+	wait    time.Duration
+	maxWait time.Duration
+	groupBy []string
+	groups  map[string]*digestGroup
+	send    func(*AlertList)
+}
+
+// Start returns the digester of the settings, the groups are given to send once their
+// window ends
+func (d Digest) Start(send func(*AlertList)) *digester {
+	maxWait := d.MaxWait
+	if maxWait == 0 {
+		maxWait = d.Wait
+	}
+
+	return newDigester(time.Duration(d.Wait)*time.Second, time.Duration(maxWait)*time.Second,
+		d.GroupBy, send)
+}
+
+func newDigester(wait, maxWait time.Duration, groupBy []string, send func(*AlertList)) *digester {
+	return &digester{
+		wait:    wait,
+		maxWait: maxWait,
+		groupBy: groupBy,
+		groups:  map[string]*digestGroup{},
+		send:    send,
+	}
+}
+
+// key returns the group of an alert
+func (d *digester) key(a Alert) string {
+	values := []string{}
+	for _, key := range d.groupBy {
+		switch key {
+		case GroupByHost:
+			values = append(values, a.Host)
+		case GroupByProject:
+			values = append(values, a.Project)
+		case GroupByContainer:
+			values = append(values, a.Container)
+		case GroupByCheck:
+			values = append(values, a.Check)
+		}
+	}
+
+	return strings.Join(values, "\x00")
+}
+
+// Add adds the alerts to their group, each new alert extends the window of its group until
+// the max wait. The informational alerts are sent right away.
+func (d *digester) Add(a *AlertList) {
+	info := &AlertList{Alerts: []Alert{}}
+
+	d.Lock()
+	for _, alert := range a.Alerts {
+		if alert.State == StateInfo {
+			info.Alerts = append(info.Alerts, alert)
+			continue
+		}
+
+		key := d.key(alert)
+		now := time.Now()
+
+		g, ok := d.groups[key]
+		if !ok {
+			g = &digestGroup{list: &AlertList{Alerts: []Alert{}}, deadline: now.Add(d.maxWait)}
+			g.timer = time.AfterFunc(d.wait, func() { d.flush(key) })
+			d.groups[key] = g
+		} else if wait := g.deadline.Sub(now); wait > d.wait {
+			g.timer.Reset(d.wait)
+		} else {
+			g.timer.Reset(wait)
+		}
+
+		g.list.Alerts = append(g.list.Alerts, alert)
+	}
+	d.Unlock()
+
+	if info.ShouldSend() {
+		d.send(info)
+	}
+}
+
+// flush sends a group when its window ends
+func (d *digester) flush(key string) {
+	d.Lock()
+	g, ok := d.groups[key]
+	if ok {
+		delete(d.groups, key)
+	}
+	d.Unlock()
+
+	if ok {
+		d.send(g.list)
+	}
+}
+
+// Flush sends every group without waiting for the end of their window
+func (d *digester) Flush() {
+	d.Lock()
+	groups := d.groups
+	d.groups = map[string]*digestGroup{}
+	d.Unlock()
+
+	for _, g := range groups {
+		g.timer.Stop()
+		d.send(g.list)
+	}
+}
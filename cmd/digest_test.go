@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDigest(t *testing.T) {
+	var mu sync.Mutex
+	sent := []string{}
+
+	d := newDigester(100*time.Millisecond, 250*time.Millisecond, []string{GroupByProject},
+		func(a *AlertList) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			containers := []string{}
+			for _, alert := range a.Alerts {
+				containers = append(containers, alert.Container)
+			}
+			sent = append(sent, strings.Join(containers, ","))
+		})
+
+	add := func(container, project string, state AlertState) {
+		a := &AlertList{}
+		a.AddAlert(Alert{Container: container, Project: project, Check: CheckRunning, State: state})
+		d.Add(a)
+	}
+
+	// the informational alerts are not held
+	add("", "", StateInfo)
+
+	// the shop alerts keep extending the window until the max wait
+	add("web", "shop", StateFailure)
+	add("blog", "site", StateFailure)
+	for _, c := range []string{"db", "cache", "queue"} {
+		time.Sleep(60 * time.Millisecond)
+		add(c, "shop", StateFailure)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	add("worker", "shop", StateRecovery)
+	d.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the site group was sent first, then shop at its max wait with the alerts added until
+	// then, and the worker recovery on flush
+	expected := []string{"", "blog", "web,db,cache,queue", "worker"}
+	if len(sent) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, sent)
+	}
+
+	sort.Strings(sent[3:])
+	for i := range expected {
+		if sent[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, sent)
+			break
+		}
+	}
+}
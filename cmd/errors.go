@@ -16,7 +16,44 @@ var (
 	ErrEmailNoPass           = errors.New("no email password")
 	ErrEmailNoPort           = errors.New("no email port")
 	ErrEmailNoSubject        = errors.New("no email subject")
+	ErrEmailAuth             = errors.New("unknown email auth (none, plain, login or cram-md5)")
+	ErrEmailEncryption       = errors.New("unknown email encryption (opportunistic, starttls, tls or none)")
 	ErrSlackNoWebHookURL     = errors.New("no slack webhook url")
+	ErrSlackWebhookAndToken  = errors.New("slack webhook url and bot token are exclusive")
+	ErrSlackNoChannel        = errors.New("no slack channel for the bot token")
+	ErrMatrixHomeserverURL   = errors.New("no matrix homeserver url")
+	ErrMatrixAccessToken     = errors.New("no matrix access token")
+	ErrMatrixRoomID          = errors.New("no matrix room id")
+	ErrMattermostNoWebHookURL = errors.New("no mattermost webhook url")
+	ErrFallbackTooShort      = errors.New("a fallback chain needs at least two alerters")
+	ErrFallbackUnknown       = errors.New("fallback alerter is not configured")
+	ErrFallbackDuplicate     = errors.New("alerter is in several fallback chains")
+	ErrDigestMaxWait         = errors.New("digest maxWait must be greater than wait")
+	ErrDigestGroupBy         = errors.New("unknown digest group (host, project, container or check)")
+	ErrQuietHoursEmpty       = errors.New("quiet hours start and end must differ")
+	ErrQuietHoursSeverity    = errors.New("unknown quiet hours severity (info, warning, error or critical)")
+	ErrQuietHoursUnknown     = errors.New("quiet hours alerter is not configured")
+	ErrQuietHoursFallback    = errors.New("quiet hours of a fallback alerter, set them on the first alerter of its chain")
+	ErrEscalationFirstTier   = errors.New("the first escalation tier must be notified after 0 minutes")
+	ErrEscalationOrder       = errors.New("escalation tiers must be in increasing order of minutes")
+	ErrEscalationAlerter     = errors.New("escalation alerter is not configured or is a fallback")
+	ErrInhibitionMode        = errors.New("unknown inhibition mode (suppress or annotate)")
+	ErrInhibitionCheck       = errors.New("unknown inhibition check (exist, running, cpu, memory or min-pid)")
+	ErrDependsOnUnknown      = errors.New("dependency is not a monitored container")
+	ErrAckNotActive          = errors.New("no active alert to acknowledge")
+	ErrAckAlready            = errors.New("alert already acknowledged")
+	ErrAPIAddress            = errors.New("no api address")
+	ErrAPIToken              = errors.New("the api needs a token")
+	ErrSilencesPath          = errors.New("no silences path")
+	ErrSilenceNotFound       = errors.New("silence not found")
+	ErrSilenceEnd            = errors.New("silence end must be after its start")
+	ErrSilenceSchedule       = errors.New("static silences need a schedule")
+	ErrSilenceDuration       = errors.New("silence schedule needs a duration")
+	ErrHistoryPath           = errors.New("no history path")
+	ErrHistoryState          = errors.New("unknown history state (failure or recovery)")
+	ErrDeliveryRateLimit     = errors.New("rate limit must be positive")
+	ErrDeliveryRetries       = errors.New("delivery retries must not be negative")
+	ErrDeliveryBackoff       = errors.New("delivery maxBackoff must be greater than backoff")
 	ErrNoContainers          = errors.New("there were no containers found in the configuration file")
 	ErrExistCheckFail        = errors.New("Existence check failure")
 	ErrExistCheckRecovered   = errors.New("Existence check recovered")
@@ -30,12 +67,34 @@ var (
 	ErrMinPIDCheckRecovered  = errors.New("Min PID check recovered")
 	ErrMaxPIDCheckFail       = errors.New("Max PID check Failure")
 	ErrMaxPIDCheckRecovered  = errors.New("Max PID check recovered")
+	ErrDaemonCheckFail       = errors.New("Docker daemon unreachable")
+	ErrDaemonCheckRecovered  = errors.New("Docker daemon reachable")
 	ErrUnknown               = errors.New("Received an unknown error")
 	ErrPushoverAPIToken      = errors.New("no pushover api token")
 	ErrPushoverUserKey       = errors.New("no pushover user key")
 	ErrPushoverAPIURL        = errors.New("no pushover api url")
+	ErrPushoverPriority      = errors.New("pushover priorities must be between -2 and 2")
+	ErrPushoverRetry         = errors.New("pushover retry must be at least 30 seconds")
+	ErrPushoverExpire        = errors.New("pushover expire must be at most 10800 seconds")
 	ErrPushbulletAccessToken = errors.New("no pushbullet access token")
 	ErrPushbulletTitle		 = errors.New("no pushbullet title")
+	ErrAlertmanagerURL       = errors.New("no alertmanager url")
+	ErrSyslogNetwork         = errors.New("unknown syslog network (unix, unixgram, udp or tcp)")
+	ErrSyslogAddress         = errors.New("no syslog address")
+	ErrSyslogFacility        = errors.New("unknown syslog facility")
+	ErrExecNoCommand         = errors.New("no exec command")
+	ErrMQTTBroker            = errors.New("no mqtt broker")
+	ErrMQTTQoS               = errors.New("mqtt qos must be 0, 1 or 2")
+	ErrMQTTCertificate       = errors.New("mqtt certFile and keyFile must be set together")
+	ErrGotifyURL             = errors.New("no gotify url")
+	ErrGotifyToken           = errors.New("no gotify token")
+	ErrNtfyTopic             = errors.New("no ntfy topic")
+	ErrNtfyPriority          = errors.New("ntfy priorities must be between 1 and 5")
+	ErrSMSAccountSID         = errors.New("no sms account sid")
+	ErrSMSAuthToken          = errors.New("no sms auth token")
+	ErrSMSFrom               = errors.New("no sms from number")
+	ErrSMSTo                 = errors.New("no sms to numbers")
+	ErrSMSMaxLength          = errors.New("sms max length must be between 1 and 1600")
 )
 
 // ErrContainsErr returns true if the error string contains the message
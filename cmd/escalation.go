@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Escalation is an escalation policy, it applies to the alerts of the Containers (names or
+// patterns) and Checks, every alert when they are empty. The failures are only sent to the
+// first tier, each next tier is notified once the failure has been active and
+// unacknowledged for its After minutes. The recoveries are sent to the notified tiers.
+type Escalation struct {
+	Containers []string
+	Checks     []string
+	Tiers      []EscalationTier
+}
+
+// EscalationTier is a tier of an escalation policy
+type EscalationTier struct {
+	After    uint64
+	Alerters []string
+}
+
+// EscalationData is the data of the escalation templates
+type EscalationData struct {
+	Alert
+	Tier    int
+	Minutes uint64
+}
+
+// Valid returns an error if the escalation policy is invalid, the alerters must be the first
+// alerters of their fallback chains
+func (e Escalation) Valid(heads map[string]bool) error {
+	errString := []string{}
+
+	for _, pattern := range e.Containers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errString = append(errString, errors.Wrapf(err, "invalid container %q", pattern).Error())
+		}
+	}
+
+	if len(e.Tiers) == 0 || e.Tiers[0].After != 0 {
+		errString = append(errString, ErrEscalationFirstTier.Error())
+	}
+
+	for i, tier := range e.Tiers {
+		if i > 0 && tier.After <= e.Tiers[i-1].After {
+			errString = append(errString, ErrEscalationOrder.Error())
+		}
+
+		for _, name := range tier.Alerters {
+			if !heads[strings.ToLower(name)] {
+				errString = append(errString, errors.Wrap(ErrEscalationAlerter, name).Error())
+			}
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "escalation settings validation fail")
+}
+
+// Matches returns true if the policy applies to the alert
+func (e Escalation) Matches(a Alert) bool {
+	if a.Check == "" {
+		return false
+	}
+
+	container := len(e.Containers) == 0
+	for _, pattern := range e.Containers {
+		if ok, _ := path.Match(pattern, a.Container); ok {
+			container = true
+		}
+	}
+
+	check := len(e.Checks) == 0
+	for _, c := range e.Checks {
+		if c == a.Check {
+			check = true
+		}
+	}
+
+	return container && check
+}
+
+// escalationState is the last notified tier of the failure of a check, it is saved in the
+// state file
+type escalationState struct {
+	Since time.Time `json:"since"`
+	Tier  int       `json:"tier"`
+}
+
+// escalator routes the alerts matched by the escalation policies to their notified tiers
+// and notifies the next tiers of the active failures
+type escalator struct {
+	sync.Mutex
+	policies  []Escalation
+	templates *TemplateConfig
+	routes    []AlerterChain
+	reached   map[string]escalationState
+}
+
+// newEscalator returns the escalator of the policies
+func newEscalator(policies []Escalation, t *TemplateConfig, routes []AlerterChain) *escalator {
+	return &escalator{policies: policies, templates: t, routes: routes,
+		reached: map[string]escalationState{}}
+}
+
+// policy returns the first policy matching the alert
+func (e *escalator) policy(a Alert) (Escalation, bool) {
+	for _, p := range e.policies {
+		if p.Matches(a) {
+			return p, true
+		}
+	}
+
+	return Escalation{}, false
+}
+
+// tier returns the last notified tier of the alert, a new failure starts at the first tier
+func (e *escalator) tier(a Alert) int {
+	e.Lock()
+	defer e.Unlock()
+
+	state, ok := e.reached[a.Key()]
+	if ok && a.State == StateFailure && !state.Since.Equal(a.Since) {
+		// the tiers of a previous failure of the check
+		delete(e.reached, a.Key())
+		return 0
+	}
+	if !ok {
+		return 0
+	}
+
+	return state.Tier
+}
+
+// Forget removes the notified tiers of the recovered checks once their recoveries have been
+// routed
+func (e *escalator) Forget(a *AlertList) {
+	e.Lock()
+	defer e.Unlock()
+
+	for _, alert := range a.Alerts {
+		if alert.State == StateRecovery {
+			delete(e.reached, alert.Key())
+		}
+	}
+}
+
+// Reached returns the notified tiers of the active failures
+func (e *escalator) Reached() map[string]escalationState {
+	e.Lock()
+	defer e.Unlock()
+
+	reached := map[string]escalationState{}
+	for key, state := range e.reached {
+		reached[key] = state
+	}
+
+	return reached
+}
+
+// Restore sets the notified tier of a failure saved by a previous run, so that the tiers
+// which were already notified are not notified again
+func (e *escalator) Restore(key string, state escalationState) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.reached[key] = state
+}
+
+// Filter returns the alerts of the list to send to the chain, the alerts matched by a
+// policy are only sent to its notified tiers
+func (e *escalator) Filter(chain AlerterChain, a *AlertList) *AlertList {
+	list := &AlertList{Alerts: []Alert{}, Fallback: a.Fallback}
+
+	for _, alert := range a.Alerts {
+		p, ok := e.policy(alert)
+		if !ok || p.notifies(chain.Name(), e.tier(alert)) {
+			list.Alerts = append(list.Alerts, alert)
+		}
+	}
+
+	return list
+}
+
+// notifies returns true if the alerter is in one of the tiers up to the given one
+func (e Escalation) notifies(name string, tier int) bool {
+	for i := 0; i <= tier && i < len(e.Tiers); i++ {
+		for _, alerter := range e.Tiers[i].Alerters {
+			if strings.ToLower(alerter) == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Escalate notifies the next tiers of the active failures which are not acknowledged nor
+// inhibited
+func (e *escalator) Escalate() {
+	now := time.Now()
+
+	for _, active := range activeAlerts.List() {
+		p, ok := e.policy(active.Alert)
+		if !ok || active.Ack != nil || active.Inhibited {
+			continue
+		}
+
+		current := e.tier(active.Alert)
+		target := current
+		for i, tier := range p.Tiers {
+			if now.Sub(active.Alert.Since) >= time.Duration(tier.After)*time.Minute {
+				target = i
+			}
+		}
+		if target <= current {
+			continue
+		}
+
+		e.Lock()
+		e.reached[active.Alert.Key()] = escalationState{Since: active.Alert.Since, Tier: target}
+		e.Unlock()
+
+		names := map[string]bool{}
+		for _, tier := range p.Tiers[current+1 : target+1] {
+			for _, name := range tier.Alerters {
+				names[strings.ToLower(name)] = true
+			}
+		}
+
+		routes := []AlerterChain{}
+		for _, chain := range e.routes {
+			if names[chain.Name()] {
+				routes = append(routes, chain)
+			}
+		}
+
+		var message bytes.Buffer
+		var title bytes.Buffer
+
+		data := EscalationData{active.Alert, target, p.Tiers[target].After}
+		e.templates.Executor.ExecuteTemplate(&message, "escalation-message", data)
+		e.templates.Executor.ExecuteTemplate(&title, "escalation-title", data)
+
+		alert := active.Alert
+		alert.Message, alert.Title, alert.Time = message.String(), title.String(), now
+		alert.FollowUp = true
+
+		list := &AlertList{Alerts: []Alert{}}
+		list.AddAlert(alert)
+		list.Send(routes)
+	}
+}
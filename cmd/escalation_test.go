@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEscalation(t *testing.T) {
+	defer func(r *alertRegistry, e *escalator) {
+		activeAlerts, Config.escalator = r, e
+	}(activeAlerts, Config.escalator)
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+	templates, err := TemplateConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := &recordingAlerter{}, &syslogRecorder{}
+	routes := []AlerterChain{{first}, {second}}
+
+	policy := Escalation{
+		Containers: []string{"db*"},
+		Tiers: []EscalationTier{
+			{After: 0, Alerters: []string{"recordingAlerter"}},
+			{After: 10, Alerters: []string{"syslogrecorder"}},
+		},
+	}
+	heads := map[string]bool{"recordingalerter": true, "syslogrecorder": true}
+	if err := policy.Valid(heads); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Escalation{Tiers: []EscalationTier{{After: 5}}}).Valid(heads); err == nil {
+		t.Error("expected an error for a first tier after 5 minutes")
+	}
+
+	Config.escalator = newEscalator([]Escalation{policy}, &templates, routes)
+
+	send := func(title string, state AlertState, since time.Time) {
+		a := &AlertList{}
+		a.AddAlert(Alert{Title: title, Container: "db", Check: CheckRunning, State: state, Since: since})
+		a.Send(routes)
+		deliveries.Wait()
+	}
+
+	// the failure is only sent to the first tier
+	failure := Alert{Title: "down", Container: "db", Check: CheckRunning, State: StateFailure,
+		Since: time.Now().Add(-15 * time.Minute)}
+	activeAlerts.Activate(failure)
+	send("down", StateFailure, failure.Since)
+
+	if first.titles() != "down" || second.titles() != "" {
+		t.Fatalf("expected the failure on the first tier only, got %q and %q", first.titles(),
+			second.titles())
+	}
+
+	// the second tier is notified once, after 10 minutes
+	Config.escalator.Escalate()
+	deliveries.Wait()
+	Config.escalator.Escalate()
+	deliveries.Wait()
+
+	if first.titles() != "down" || second.titles() != "Escalated: down" {
+		t.Fatalf("expected the escalation on the second tier, got %q and %q", first.titles(),
+			second.titles())
+	}
+
+	// the recovery is sent to the notified tiers
+	activeAlerts.Resolve(failure.Key())
+	send("up", StateRecovery, failure.Since)
+
+	if first.titles() != "down,up" || second.titles() != "Escalated: down,up" {
+		t.Errorf("expected the recovery on both tiers, got %q and %q", first.titles(),
+			second.titles())
+	}
+
+	// the tiers of the recovered check are forgotten
+	if reached := Config.escalator.Reached(); len(reached) != 0 {
+		t.Errorf("expected the notified tiers to be forgotten, got %+v", reached)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+)
+
+// AlerterChain is a list of alerters where each one is only used when the previous ones
+// failed to deliver the alerts, an alerter without fallback is a chain of its own
+type AlerterChain []Alerter
+
+// Name returns the name of the chain, the one of its first alerter
+func (c AlerterChain) Name() string {
+	return alerterName(c[0])
+}
+
+// Fallback describes a fallback delivery, the alerter which failed with its error and the
+// alerter which receives the alerts instead
+type Fallback struct {
+	Alerter string `json:"alerter"`
+	Failed  string `json:"failed"`
+	Error   string `json:"error"`
+}
+
+// parseFallback returns the alerter names of a fallback chain, "email -> slack -> syslog"
+func parseFallback(s string) []string {
+	names := []string{}
+	for _, name := range strings.Split(s, "->") {
+		names = append(names, strings.ToLower(strings.TrimSpace(name)))
+	}
+
+	return names
+}
+
+// Routes returns the configured alerters grouped by fallback chain, in the order of the
+// alerters
+func (c *Conf) Routes() []AlerterChain {
+	alerters := map[string]Alerter{}
+	for _, b := range c.Alerters {
+		alerters[alerterName(b)] = b
+	}
+
+	chains := map[string]AlerterChain{}
+	for _, fallback := range c.Fallbacks {
+		chain := AlerterChain{}
+		for _, name := range parseFallback(fallback) {
+			if b, ok := alerters[name]; ok {
+				chain = append(chain, b)
+			}
+		}
+
+		for _, b := range chain {
+			chains[alerterName(b)] = chain
+		}
+	}
+
+	routes := []AlerterChain{}
+	added := map[string]bool{}
+
+	for _, b := range c.Alerters {
+		chain, ok := chains[alerterName(b)]
+		if !ok {
+			chain = AlerterChain{b}
+		}
+
+		if !added[chain.Name()] {
+			added[chain.Name()] = true
+			routes = append(routes, chain)
+		}
+	}
+
+	return routes
+}
+
+// asFallback returns a copy of the list for a fallback alerter, with a note about the
+// failed delivery rendered from the fallback templates
+func (a *AlertList) asFallback(alerter, failed string, err error) *AlertList {
+	fallback := &Fallback{Alerter: alerter, Failed: failed, Error: err.Error()}
+
+	list := &AlertList{Alerts: []Alert{}, Fallback: fallback}
+
+	var message bytes.Buffer
+	var title bytes.Buffer
+
+	// the templates are missing when the configuration has not been validated
+	errMessage := Config.Templates.Executor.ExecuteTemplate(&message, "fallback-message", fallback)
+	errTitle := Config.Templates.Executor.ExecuteTemplate(&title, "fallback-title", fallback)
+	if errMessage == nil && errTitle == nil {
+		list.Add(message.String(), title.String(), nil)
+	}
+
+	list.Alerts = append(list.Alerts, a.Alerts...)
+
+	return list
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeliverFallback(t *testing.T) {
+	defer func(c Conf) { Config = c }(Config)
+	templates, err := TemplateConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Config.Templates = templates
+
+	retries := 0
+	d := Delivery{Retries: &retries}
+
+	down, up, unused := &recordingAlerter{down: true}, &recordingAlerter{}, &recordingAlerter{}
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", State: StateFailure})
+
+	if err := d.Deliver(context.Background(), AlerterChain{down, up, unused}, a); err != nil {
+		t.Fatal(err)
+	}
+
+	if up.titles() != "Fallback delivery CPU check failure" || unused.titles() != "" {
+		t.Errorf("expected the fallback to receive the alert once, got %q and %q", up.titles(),
+			unused.titles())
+	}
+
+	f := up.last.Fallback
+	if f == nil || f.Failed != "recordingalerter" || f.Error != "test responded with status 502 Bad Gateway" {
+		t.Errorf("unexpected fallback data: %+v", f)
+	}
+
+	if note := up.last.Alerts[0]; note.State != StateInfo ||
+		note.Message != "recordingalerter failed (test responded with status 502 Bad Gateway), sent to recordingalerter instead" {
+		t.Errorf("unexpected fallback note: %+v", note)
+	}
+
+	if len(a.Alerts) != 1 || a.Fallback != nil {
+		t.Errorf("the original list should not be modified: %+v", a)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	c := &Conf{
+		Alerters:  []Alerter{Email{}, Syslog{}, Pushover{}, Slack{}},
+		Fallbacks: []string{"email -> Slack->syslog"},
+	}
+
+	if err := c.ValidateFallbackSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := c.Routes()
+	if len(routes) != 2 || len(routes[0]) != 3 || len(routes[1]) != 1 {
+		t.Fatalf("expected the email chain and pushover, got %v", routes)
+	}
+
+	names := []string{}
+	for _, b := range routes[0] {
+		names = append(names, alerterName(b))
+	}
+	if routes[0].Name() != "email" || names[1] != "slack" || names[2] != "syslog" ||
+		routes[1].Name() != "pushover" {
+		t.Errorf("unexpected routes: %v, %s", names, routes[1].Name())
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyFilter HistoryFilter
+	historyState  string
+	historySince  string
+	historyJSON   bool
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "show the history of the alerts",
+	Long: `Show the failures and recoveries recorded in the history path of the config file, with
+the duration of their incidents and the outcome of their delivery to each alerter, e.g.:
+
+docker-alertd history --container "web-*" --since 24h --state failure`,
+	Run: func(cmd *cobra.Command, args []string) {
+		f := historyFilter
+
+		switch AlertState(historyState) {
+		case "", StateFailure, StateRecovery:
+			f.State = AlertState(historyState)
+		default:
+			log.Fatal(errors.Wrap(ErrHistoryState, historyState))
+		}
+
+		if historySince != "" {
+			if d, err := time.ParseDuration(historySince); err == nil {
+				f.Since = time.Now().Add(-d)
+			} else if f.Since, err = time.Parse(time.RFC3339, historySince); err != nil {
+				log.Fatal(errors.Wrap(err, "since must be a duration or a RFC3339 time"))
+			}
+		}
+
+		entries, err := ReadHistory(Config.History.Path, f)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if historyJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(entries); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tCONTAINER\tCHECK\tSTATE\tVALUE\tLIMIT\tDURATION\tDELIVERIES")
+		for _, e := range entries {
+			duration := e.Duration.Round(time.Second).String()
+			if e.Ongoing {
+				duration += " (ongoing)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Format(time.RFC3339),
+				e.Container, e.Check, e.State, optionalUint(e.Value), optionalUint(e.Limit),
+				duration, formatDeliveries(e.Deliveries))
+		}
+		w.Flush()
+	},
+}
+
+// optionalUint formats a metric of the history, "-" when there is none
+func optionalUint(v *uint64) string {
+	if v == nil {
+		return "-"
+	}
+
+	return strconv.FormatUint(*v, 10)
+}
+
+// formatDeliveries lists the alerters of the deliveries, with the error of the failed ones
+func formatDeliveries(deliveries map[string]DeliveryOutcome) string {
+	if len(deliveries) == 0 {
+		return "-"
+	}
+
+	names := []string{}
+	for name, outcome := range deliveries {
+		if outcome.Error != "" {
+			name += " (failed: " + outcome.Error + ")"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringVar(&historyFilter.Container, "container", "", "container name or pattern")
+	historyCmd.Flags().StringVar(&historyFilter.Check, "check", "", "check name")
+	historyCmd.Flags().StringVar(&historyState, "state", "", "failure or recovery")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "duration (e.g. 24h) or RFC3339 time")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "JSON output")
+}
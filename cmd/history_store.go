@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// the buckets of the history database
+var (
+	historyTransitions = []byte("transitions")
+	historyIndex       = []byte("index")
+)
+
+// History contains the settings of the history of the alerts. When a path is set, every
+// failure and recovery of the checks is recorded there with the outcome of its delivery to
+// each alerter. The transitions replaced by a storm alert have no delivery outcome, the
+// storm alert is not recorded. The transitions older than MaxAge days are removed on start,
+// they are kept forever by default.
+type History struct {
+	Path   string
+	MaxAge uint64
+}
+
+// DeliveryOutcome is the result of the delivery of a transition to an alerter chain, it was
+// delivered if there is no error
+type DeliveryOutcome struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Transition is a failure or a recovery of a check recorded in the history
+type Transition struct {
+	ID         uint64                     `json:"id"`
+	Container  string                     `json:"container,omitempty"`
+	Check      string                     `json:"check"`
+	State      AlertState                 `json:"state"`
+	Severity   string                     `json:"severity,omitempty"`
+	Host       string                     `json:"host,omitempty"`
+	Title      string                     `json:"title"`
+	Value      *uint64                    `json:"value,omitempty"`
+	Limit      *uint64                    `json:"limit,omitempty"`
+	Since      time.Time                  `json:"since"`
+	Time       time.Time                  `json:"time"`
+	Deliveries map[string]DeliveryOutcome `json:"deliveries,omitempty"`
+}
+
+// historyKey identifies the transition of an alert, the reminders and escalations of a
+// failure have the same key
+func historyKey(a Alert) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s/%d", a.Container, a.Check, a.State, a.Since.UnixNano()))
+}
+
+// recorded returns true if the alert is a transition of a check
+func recorded(a Alert) bool {
+	return a.Check != "" && (a.State == StateFailure || a.State == StateRecovery)
+}
+
+// historyStore records the transitions, the database is only opened while it is written so
+// that the history command can read it while the daemon is running
+type historyStore struct {
+	sync.Mutex
+	path string
+}
+
+// Open creates the history database if needed and removes the expired transitions
+func (h History) Open() (*historyStore, error) {
+	store := &historyStore{path: h.Path}
+
+	err := store.update(func(tx *bolt.Tx) error {
+		transitions, err := tx.CreateBucketIfNotExists(historyTransitions)
+		if err != nil {
+			return err
+		}
+		index, err := tx.CreateBucketIfNotExists(historyIndex)
+		if err != nil {
+			return err
+		}
+
+		if h.MaxAge == 0 {
+			return nil
+		}
+
+		expired := time.Now().Add(-time.Duration(h.MaxAge) * 24 * time.Hour)
+		keys := [][]byte{}
+		err = transitions.ForEach(func(k, v []byte) error {
+			var t Transition
+			if err := json.Unmarshal(v, &t); err == nil && t.Time.After(expired) {
+				return nil
+			}
+
+			alert := Alert{Container: t.Container, Check: t.Check, State: t.State, Since: t.Since}
+			keys = append(keys, k)
+			return index.Delete(historyKey(alert))
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := transitions.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error initializing the history")
+	}
+
+	return store, nil
+}
+
+// update opens the database and runs the transaction
+func (h *historyStore) update(fn func(tx *bolt.Tx) error) error {
+	h.Lock()
+	defer h.Unlock()
+
+	db, err := bolt.Open(h.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return errors.Wrapf(err, "error opening the history %s", h.path)
+	}
+	defer db.Close()
+
+	return db.Update(fn)
+}
+
+// Record adds the failures and recoveries of the list to the history, the database is not
+// opened when there is none
+func (h *historyStore) Record(a *AlertList) {
+	if h == nil {
+		return
+	}
+
+	transitions := false
+	for _, alert := range a.Alerts {
+		transitions = transitions || recorded(alert)
+	}
+	if !transitions {
+		return
+	}
+
+	err := h.update(func(tx *bolt.Tx) error {
+		transitions, index := tx.Bucket(historyTransitions), tx.Bucket(historyIndex)
+
+		for _, alert := range a.Alerts {
+			if !recorded(alert) {
+				continue
+			}
+
+			seq, err := transitions.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			value, err := json.Marshal(Transition{
+				ID:        seq,
+				Container: alert.Container,
+				Check:     alert.Check,
+				State:     alert.State,
+				Severity:  alert.Severity,
+				Host:      alert.Host,
+				Title:     alert.Title,
+				Value:     alert.Value,
+				Limit:     alert.Limit,
+				Since:     alert.Since,
+				Time:      alert.Time,
+			})
+			if err != nil {
+				return err
+			}
+
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, seq)
+
+			if err := transitions.Put(key, value); err != nil {
+				return err
+			}
+			if err := index.Put(historyKey(alert), key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println(errors.Wrap(err, "error recording the history"))
+	}
+}
+
+// Delivered records the outcome of the delivery of the alerts to the alerter chain, a
+// successful delivery is not overwritten by a later failed reminder
+func (h *historyStore) Delivered(name string, a *AlertList, delivery error) {
+	if h == nil {
+		return
+	}
+
+	outcome := DeliveryOutcome{Time: time.Now()}
+	if delivery != nil {
+		outcome.Error = delivery.Error()
+	}
+
+	err := h.update(func(tx *bolt.Tx) error {
+		transitions, index := tx.Bucket(historyTransitions), tx.Bucket(historyIndex)
+
+		for _, alert := range a.Alerts {
+			if !recorded(alert) {
+				continue
+			}
+
+			key := index.Get(historyKey(alert))
+			if key == nil {
+				continue
+			}
+
+			var t Transition
+			if err := json.Unmarshal(transitions.Get(key), &t); err != nil {
+				return err
+			}
+
+			if previous, ok := t.Deliveries[name]; ok && previous.Error == "" {
+				continue
+			}
+			if t.Deliveries == nil {
+				t.Deliveries = map[string]DeliveryOutcome{}
+			}
+			t.Deliveries[name] = outcome
+
+			value, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			if err := transitions.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println(errors.Wrap(err, "error recording the delivery in the history"))
+	}
+}
+
+// HistoryFilter selects the transitions of the history, the zero values match everything
+type HistoryFilter struct {
+	Container string
+	Check     string
+	State     AlertState
+	Since     time.Time
+}
+
+// Matches returns true if the transition is selected by the filter, the container can be
+// a pattern
+func (f HistoryFilter) Matches(t Transition) bool {
+	if f.Container != "" {
+		if ok, _ := path.Match(f.Container, t.Container); !ok {
+			return false
+		}
+	}
+
+	return (f.Check == "" || f.Check == t.Check) && (f.State == "" || f.State == t.State) &&
+		!t.Time.Before(f.Since)
+}
+
+// HistoryEntry is a transition with the duration of its incident. The duration of a failure
+// runs until the next transition of the check, or until now if it is still ongoing, and the
+// one of a recovery since the failure it ends.
+type HistoryEntry struct {
+	Transition
+	Duration time.Duration
+	Ongoing  bool
+}
+
+// MarshalJSON encodes the entry with its duration in seconds
+func (e HistoryEntry) MarshalJSON() ([]byte, error) {
+	type transition Transition
+
+	return json.Marshal(struct {
+		transition
+		Duration float64 `json:"duration"`
+		Ongoing  bool    `json:"ongoing,omitempty"`
+	}{transition(e.Transition), e.Duration.Seconds(), e.Ongoing})
+}
+
+// ReadHistory returns the transitions of the history selected by the filter in
+// chronological order
+func ReadHistory(file string, f HistoryFilter) ([]HistoryEntry, error) {
+	if file == "" {
+		return nil, ErrHistoryPath
+	}
+
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the history %s", file)
+	}
+	defer db.Close()
+
+	transitions := []Transition{}
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyTransitions)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var t Transition
+			if err := json.Unmarshal(v, &t); err != nil {
+				return errors.Wrapf(err, "error decoding the transition %d",
+					binary.BigEndian.Uint64(k))
+			}
+			transitions = append(transitions, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return historyEntries(transitions, f, time.Now()), nil
+}
+
+// historyEntries computes the durations of the incidents of the transitions, before the
+// filter so that a failure ends with its recovery even if the recovery is not selected
+func historyEntries(transitions []Transition, f HistoryFilter, now time.Time) []HistoryEntry {
+	entries := []HistoryEntry{}
+	failures := map[string]int{}
+
+	for _, t := range transitions {
+		key := t.Container + "/" + t.Check
+
+		// the previous failure of the check ends with this transition
+		if i, ok := failures[key]; ok {
+			entries[i].Duration, entries[i].Ongoing = t.Time.Sub(entries[i].Time), false
+			delete(failures, key)
+		}
+
+		entry := HistoryEntry{Transition: t}
+		switch t.State {
+		case StateFailure:
+			entry.Duration, entry.Ongoing = now.Sub(t.Time), true
+			failures[key] = len(entries)
+		case StateRecovery:
+			entry.Duration = t.Time.Sub(t.Since)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	selected := []HistoryEntry{}
+	for _, entry := range entries {
+		if f.Matches(entry.Transition) {
+			selected = append(selected, entry)
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := History{Path: filepath.Join(dir, "history.db")}
+	h, err := settings.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	value, limit := uint64(95), uint64(80)
+	failure := Alert{Container: "web", Check: CheckCPU, State: StateFailure, Since: since,
+		Time: since, Value: &value, Limit: &limit}
+	recovery := Alert{Container: "web", Check: CheckCPU, State: StateRecovery, Since: since,
+		Time: since.Add(10 * time.Minute)}
+	db := Alert{Container: "db", Check: CheckRunning, State: StateFailure, Since: since,
+		Time: since.Add(30 * time.Minute)}
+
+	// the informational alerts are not recorded, the database is not written without
+	// transitions
+	os.Chtimes(settings.Path, time.Unix(0, 0), time.Unix(0, 0))
+	h.Record(&AlertList{Alerts: []Alert{{Title: "starting", State: StateInfo}}})
+	if info, _ := os.Stat(settings.Path); info.ModTime().Unix() != 0 {
+		t.Error("expected the history not to be written without transitions")
+	}
+	h.Record(&AlertList{Alerts: []Alert{failure, {Title: "starting", State: StateInfo}}})
+	h.Record(&AlertList{Alerts: []Alert{recovery, db}})
+
+	retries := 0
+	d := Delivery{Retries: &retries, history: h}
+	d.Deliver(context.Background(), AlerterChain{&recordingAlerter{}}, &AlertList{Alerts: []Alert{failure}})
+	d.Deliver(context.Background(), AlerterChain{&recordingAlerter{down: true}}, &AlertList{Alerts: []Alert{db}})
+
+	// a failed reminder does not overwrite the delivery of the failure
+	h.Delivered("recordingalerter", &AlertList{Alerts: []Alert{failure}}, ErrAPIAddress)
+
+	entries, err := ReadHistory(settings.Path, HistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 transitions, got %+v", entries)
+	}
+
+	web := entries[0]
+	if web.Duration != 10*time.Minute || web.Ongoing || *web.Value != 95 || *web.Limit != 80 {
+		t.Errorf("unexpected failure %+v", web)
+	}
+	if outcome, ok := web.Deliveries["recordingalerter"]; !ok || outcome.Error != "" {
+		t.Errorf("expected the failure to be delivered, got %+v", web.Deliveries)
+	}
+	if entries[1].Duration != 10*time.Minute {
+		t.Errorf("expected the recovery to end a 10 minutes incident, got %s", entries[1].Duration)
+	}
+	if !entries[2].Ongoing || entries[2].Deliveries["recordingalerter"].Error == "" {
+		t.Errorf("expected an ongoing failure which was not delivered, got %+v", entries[2])
+	}
+
+	for _, expected := range []struct {
+		filter HistoryFilter
+		count  int
+	}{
+		{HistoryFilter{Container: "w*"}, 2},
+		{HistoryFilter{Check: CheckRunning}, 1},
+		{HistoryFilter{State: StateFailure}, 2},
+		{HistoryFilter{Since: since.Add(5 * time.Minute)}, 2},
+	} {
+		entries, err := ReadHistory(settings.Path, expected.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != expected.count {
+			t.Errorf("expected %d transitions for %+v, got %d", expected.count, expected.filter, len(entries))
+		}
+	}
+
+	// the expired transitions are removed on start
+	settings.MaxAge = 1
+	h.Record(&AlertList{Alerts: []Alert{{Container: "web", Check: CheckMemory, State: StateFailure,
+		Since: since.Add(-48 * time.Hour), Time: since.Add(-48 * time.Hour)}}})
+	if _, err := settings.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := ReadHistory(settings.Path, HistoryFilter{}); len(entries) != 3 {
+		t.Errorf("expected the expired transition to be removed, got %d transitions", len(entries))
+	}
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// the inhibition modes of the alerts of the containers whose dependencies are down
+const (
+	InhibitSuppress = "suppress"
+	InhibitAnnotate = "annotate"
+)
+
+// Inhibition contains the settings of the inhibition of the alerts of the containers
+// depending on a container whose existence or running check is active. In the suppress mode
+// (the default), their failures are held until the dependencies are back and dropped if
+// they recover in the meantime. In the annotate mode, they are sent with the dependencies
+// which likely caused them. Checks restricts the inhibited checks, all of them by default.
+type Inhibition struct {
+	Mode   string
+	Checks []string
+}
+
+// Valid returns an error if inhibition settings are invalid
+func (i Inhibition) Valid() error {
+	errString := []string{}
+
+	switch i.Mode {
+	case "", InhibitSuppress, InhibitAnnotate:
+	default:
+		errString = append(errString, errors.Wrap(ErrInhibitionMode, i.Mode).Error())
+	}
+
+	for _, check := range i.Checks {
+		switch check {
+		case CheckExist, CheckRunning, CheckCPU, CheckMinPID, CheckMemory:
+		default:
+			errString = append(errString, errors.Wrap(ErrInhibitionCheck, check).Error())
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "inhibition settings validation fail")
+}
+
+// inhibitor applies the inhibition to the alerts of the dependent containers
+type inhibitor struct {
+	settings   Inhibition
+	dependsOn  map[string][]string
+	suppressed map[string]Alert
+}
+
+// Start returns the inhibitor of the dependencies of the containers, there is none if no
+// container has dependencies
+func (i Inhibition) Start(containers []Container) *inhibitor {
+	dependsOn := map[string][]string{}
+	for _, c := range containers {
+		if len(c.DependsOn) != 0 {
+			dependsOn[c.Name] = c.DependsOn
+		}
+	}
+
+	if len(dependsOn) == 0 {
+		return nil
+	}
+
+	return &inhibitor{settings: i, dependsOn: dependsOn, suppressed: map[string]Alert{}}
+}
+
+// inhibits returns true if the check is inhibited
+func (in *inhibitor) inhibits(check string) bool {
+	if len(in.settings.Checks) == 0 {
+		return check != ""
+	}
+
+	for _, c := range in.settings.Checks {
+		if c == check {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Inhibit suppresses or annotates the alerts of the containers whose dependencies are down,
+// and releases the suppressed failures of the containers whose dependencies are back
+func (in *inhibitor) Inhibit(cnt []AlertdContainer, a *AlertList) {
+	down := map[string]bool{}
+	for _, c := range cnt {
+		down[c.Name] = c.ExistenceCheck.AlertActive || c.RunningCheck.AlertActive
+	}
+
+	causes := func(container string) []string {
+		parents := []string{}
+		for _, parent := range in.dependsOn[container] {
+			if down[parent] {
+				parents = append(parents, parent)
+			}
+		}
+		return parents
+	}
+
+	kept := []Alert{}
+	for _, alert := range a.Alerts {
+		parents := causes(alert.Container)
+		_, suppressed := in.suppressed[alert.Key()]
+
+		switch {
+		case suppressed && alert.State == StateRecovery:
+			log.Printf("dropping inhibited alert which recovered: %s\n", alert.Dump())
+			delete(in.suppressed, alert.Key())
+
+		case len(parents) == 0 || !in.inhibits(alert.Check):
+			kept = append(kept, alert)
+
+		case in.settings.Mode == InhibitAnnotate:
+			alert.CausedBy = parents
+			alert.Message = strings.TrimRight(alert.Message, "\n") + "\nlikely caused by " +
+				strings.Join(parents, ", ")
+			kept = append(kept, alert)
+
+		case alert.State == StateRecovery || alert.FollowUp:
+			// the failure was sent before the dependency went down
+			kept = append(kept, alert)
+
+		case alert.State == StateFailure:
+			log.Printf("inhibited alert, %s down: %s\n", strings.Join(parents, ", "), alert.Dump())
+			if !suppressed {
+				in.suppressed[alert.Key()] = alert
+				activeAlerts.Inhibit(alert.Key(), true)
+			}
+
+		default:
+			// the informational alerts are not useful while a dependency is down
+			log.Printf("inhibited alert, %s down: %s\n", strings.Join(parents, ", "), alert.Dump())
+		}
+	}
+
+	// the failures which are still active when their dependencies are back are sent
+	for key, alert := range in.suppressed {
+		if len(causes(alert.Container)) != 0 {
+			continue
+		}
+
+		delete(in.suppressed, key)
+		activeAlerts.Inhibit(key, false)
+		kept = append(kept, alert)
+	}
+
+	a.Alerts = kept
+}
+
+// RestoreInhibited suppresses again the restored failures which were inhibited before the
+// restart, they are sent right away if no container has dependencies anymore
+func (c *Conf) RestoreInhibited() {
+	released := &AlertList{Alerts: []Alert{}}
+	for _, active := range activeAlerts.List() {
+		switch {
+		case !active.Inhibited:
+		case c.inhibitor != nil:
+			c.inhibitor.suppressed[active.Alert.Key()] = active.Alert
+		default:
+			activeAlerts.Inhibit(active.Alert.Key(), false)
+			released.AddAlert(active.Alert)
+		}
+	}
+
+	released.Evaluate()
+}
+
+// Inhibit applies the inhibition of the dependencies of the containers to the alerts
+func (c *Conf) Inhibit(cnt []AlertdContainer, a *AlertList) {
+	if c.inhibitor != nil {
+		c.inhibitor.Inhibit(cnt, a)
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInhibit(t *testing.T) {
+	defer func(r *alertRegistry) { activeAlerts = r }(activeAlerts)
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+	containers := []Container{{Name: "db"}, {Name: "web", DependsOn: []string{"db"}}}
+	cnt := InitCheckers(&Conf{Containers: containers})
+
+	containers = append(containers, Container{Name: "api", DependsOn: []string{"cache"}})
+	if err := (&Conf{Containers: containers}).ValidateInhibitionSettings(); err == nil {
+		t.Error("expected an error for an unknown dependency")
+	}
+
+	list := func(alerts ...Alert) *AlertList {
+		a := &AlertList{}
+		for _, alert := range alerts {
+			a.AddAlert(alert)
+		}
+		return a
+	}
+	containersOf := func(a *AlertList) string {
+		names := []string{}
+		for _, alert := range a.Alerts {
+			names = append(names, alert.Container+"/"+string(alert.State))
+		}
+		return strings.Join(names, ",")
+	}
+
+	dbDown := Alert{Container: "db", Check: CheckRunning, State: StateFailure}
+	webFailure := Alert{Container: "web", Check: CheckCPU, State: StateFailure}
+	webRecovery := Alert{Container: "web", Check: CheckCPU, State: StateRecovery}
+
+	in := Inhibition{}.Start(containers)
+	cnt[0].RunningCheck.AlertActive = true
+
+	// the failure of web is held while db is down, and dropped with its recovery
+	a := list(dbDown, webFailure)
+	in.Inhibit(cnt, a)
+	if got := containersOf(a); got != "db/failure" {
+		t.Fatalf("expected the web failure to be suppressed, got %q", got)
+	}
+
+	a = list(webRecovery)
+	in.Inhibit(cnt, a)
+	if got := containersOf(a); got != "" {
+		t.Fatalf("expected the web recovery to be suppressed, got %q", got)
+	}
+
+	// a failure still active when db is back is sent
+	activeAlerts.Activate(webFailure)
+	a = list(webFailure)
+	in.Inhibit(cnt, a)
+	if active := activeAlerts.List(); !active[0].Inhibited {
+		t.Error("expected the web failure to be marked as inhibited")
+	}
+
+	cnt[0].RunningCheck.AlertActive = false
+	a = list()
+	in.Inhibit(cnt, a)
+	if got := containersOf(a); got != "web/failure" {
+		t.Fatalf("expected the web failure to be released, got %q", got)
+	}
+
+	// the recovery of a failure sent before db went down is sent
+	cnt[0].RunningCheck.AlertActive = true
+	a = list(webRecovery)
+	in.Inhibit(cnt, a)
+	if got := containersOf(a); got != "web/recovery" {
+		t.Fatalf("expected the web recovery to be sent, got %q", got)
+	}
+
+	// the reminders of a failure sent before db went down are sent, and so is its recovery
+	webReminder := webFailure
+	webReminder.FollowUp = true
+	a = list(webReminder, webRecovery)
+	in.Inhibit(cnt, a)
+	if got := containersOf(a); got != "web/failure,web/recovery" {
+		t.Fatalf("expected the web reminder and recovery to be sent, got %q", got)
+	}
+
+	// the failures inhibited before a restart are suppressed again and released with db
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+	activeAlerts.Restore(ActiveAlert{Alert: webFailure, Inhibited: true})
+	(&Conf{inhibitor: in}).RestoreInhibited()
+
+	cnt[0].RunningCheck.AlertActive = false
+	a = list()
+	in.Inhibit(cnt, a)
+	if got := containersOf(a); got != "web/failure" || activeAlerts.List()[0].Inhibited {
+		t.Fatalf("expected the restored web failure to be released, got %q", got)
+	}
+
+	// the annotate mode sends the failures with their likely cause
+	in = Inhibition{Mode: InhibitAnnotate}.Start(containers)
+	cnt[0].RunningCheck.AlertActive = true
+
+	a = list(webFailure)
+	in.Inhibit(cnt, a)
+	if len(a.Alerts) != 1 || !strings.HasSuffix(a.Alerts[0].Message, "likely caused by db") ||
+		len(a.Alerts[0].CausedBy) != 1 {
+		t.Errorf("expected the web failure to be annotated, got %+v", a.Alerts)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -30,6 +31,9 @@ func boolP(b bool) *bool {
 	return &p
 }
 
+// shutdownTimeout is how long the alerts being sent are waited for when stopping
+const shutdownTimeout = 10 * time.Second
+
 // GetStats just uses the docker API and an already tested Unmarshal function, no
 // testing needed.
 func GetStats(a *AlertdContainer, c *client.Client) (*types.Stats, error) {
@@ -67,6 +71,11 @@ func InitCheckers(c *Conf) []AlertdContainer {
 	// Taking the values from the conf and adding them into the AlertdContainers
 	var containers []AlertdContainer
 	for _, v := range c.Containers {
+		severity := v.Severity
+		if severity == "" {
+			severity = DefaultSeverity
+		}
+		
 		containers = append(containers, AlertdContainer{
 			Name: v.Name,
 			AlertList: &AlertList{
@@ -108,20 +117,27 @@ func InitCheckers(c *Conf) []AlertdContainer {
 				DelaySince:		time.Now(),
 			},
 			Templates: &c.Templates,
+			Severity:  severity,
 		})
 	}
 	return containers
 }
 
-// CheckContainers goes through and checks all the containers in a loop
+// CheckContainers goes through and checks all the containers in a loop, the containers are
+// updated in place so that they keep their last known labels
 func CheckContainers(cnt []AlertdContainer, cli *client.Client, a *AlertList) {
-	for _, c := range cnt {
+	for i := range cnt {
+		c := &cnt[i]
 		// make sure we have a clean alert for this loop
 		c.AlertList.Clear()
+		c.SyncAcks()
 
 		// handling whether the container exists, if these checks fail, the checking
 		// process should stop
-		j, err := ContainerInspect(&c, cli)
+		j, err := ContainerInspect(c, cli)
+		if client.IsErrConnectionFailed(err) {
+			return // the daemon went away, the next daemon check reports it
+		}
 		c.CheckStatics(j, err)
 
 		// if an alert should be sent that means it either failed existence or running
@@ -131,7 +147,10 @@ func CheckContainers(cnt []AlertdContainer, cli *client.Client, a *AlertList) {
 			continue
 		}
 
-		s, err := GetStats(&c, cli)
+		s, err := GetStats(c, cli)
+		if client.IsErrConnectionFailed(err) {
+			return
+		}
 		c.CheckMetrics(s, err)
 
 		if c.AlertList.ShouldSend() {
@@ -140,33 +159,63 @@ func CheckContainers(cnt []AlertdContainer, cli *client.Client, a *AlertList) {
 	}
 }
 
-// Monitor contains all the calls for the main loop of the monitor
+// Monitor contains all the calls for the main loop of the monitor, the containers are only
+// checked while the docker daemon is reachable and their transitions are recorded in the
+// history. The state of the checks is restored from the state file and saved after each
+// iteration.
 func Monitor(c *Conf, a *AlertList) {
-	cli, err := client.NewEnvClient()
-	if err != nil {
-		log.Fatal(err)
+	daemon := c.Daemon.Start(&c.Templates)
+	cnt := InitCheckers(c)
+
+	var state *stateStore
+	if c.State.Path != "" {
+		state = c.State.Open(c)
+		if err := state.Restore(cnt); err != nil {
+			log.Println(err)
+		}
+		c.RestoreInhibited()
 	}
 
-	cnt := InitCheckers(c)
+	iterate := func() {
+		a.Clear()
+		if daemon.Check(a) {
+			CheckContainers(cnt, daemon.cli, a)
+		}
+		c.history.Record(a)
+		c.FollowUp(a)
+		c.Inhibit(cnt, a)
+		c.Summarize(a)
+		a.Evaluate()
+		if state != nil {
+			if err := state.Save(cnt); err != nil {
+				log.Println(err)
+			}
+		}
+		time.Sleep(time.Duration(c.Duration) * time.Millisecond)
+	}
 
 	switch c.Iterations {
 	case 0:
 		for {
-			a.Clear()
-			CheckContainers(cnt, cli, a)
-			a.Evaluate()
-			time.Sleep(time.Duration(c.Duration) * time.Millisecond)
+			iterate()
 		}
 	default:
 		for i := uint64(0); i < c.Iterations; i++ {
-			a.Clear()
-			CheckContainers(cnt, cli, a)
-			a.Evaluate()
-			time.Sleep(time.Duration(c.Duration) * time.Millisecond)
+			iterate()
 		}
 	}
 }
 
+// FollowUp adds the reminders of the active failures to the alerts and notifies the next
+// tiers of their escalation policies
+func (c *Conf) FollowUp(a *AlertList) {
+	activeAlerts.Remind(time.Duration(c.Reminder)*time.Minute, &c.Templates, a)
+	
+	if c.escalator != nil {
+		c.escalator.Escalate()
+	}
+}
+
 func AlertStarting(c *Conf, a *AlertList) {
 	var message bytes.Buffer
 	var title bytes.Buffer
@@ -181,10 +230,12 @@ func AlertStarting(c *Conf, a *AlertList) {
 	a.Evaluate()
 }
 
-func AlertStopping(c *Conf, a *AlertList) {
-	shutdown := make(chan os.Signal)
+// AlertStopping sends the stopping alert when the daemon is interrupted or terminated, and
+// exits once the alerts have been sent
+func AlertStopping(c *Conf) {
+	shutdown := make(chan os.Signal, 1)
     
-	signal.Notify(shutdown)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 	
 	go func() {
 		<-shutdown
@@ -197,25 +248,104 @@ func AlertStopping(c *Conf, a *AlertList) {
 		c.Templates.Executor.ExecuteTemplate(&message, "stopping-message", data)
 		c.Templates.Executor.ExecuteTemplate(&title, "stopping-title", data)
 		
+		// the main list belongs to the monitor loop which is still running
+		a := &AlertList{Alerts: []Alert{}}
 		a.Add(message.String(), title.String(), nil)
 		
 		a.Evaluate()
 		
-		time.Sleep(time.Duration(5000) * time.Millisecond)
+		Shutdown(c)
 		
-		os.Exit(1)
+		os.Exit(0)
 	}()
 }
 
+// Shutdown waits for the alerts being sent until the shutdown timeout, the queued alerts
+// which are not sent by then are sent on the next start
+func Shutdown(c *Conf) {
+	if c.digest != nil {
+		c.digest.Flush()
+	}
+	
+	// the alerts held during quiet hours are sent rather than lost
+	for _, h := range c.quiet {
+		h.Flush()
+	}
+	
+	if c.queue != nil {
+		if err := c.queue.Close(shutdownTimeout); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	
+	done := make(chan struct{})
+	go func() {
+		deliveries.Wait()
+		close(done)
+	}()
+	
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		log.Println("stopping before every alert was sent")
+	}
+}
+
 // Start the main monitor loop for a set amount of iterations
 func Start(c *Conf) {
 	log.Printf("starting docker-alertd\n------------------------------")
 	a := &AlertList{Alerts: []Alert{}}
 	
+	// the queue delivers with the history to record the outcomes
+	if c.History.Path != "" {
+		h, err := c.History.Open()
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.history, c.Delivery.history = h, h
+	}
+	
+	if c.Queue.Path != "" {
+		q, err := c.Queue.Open(c.Routes(), c.Delivery)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.queue = q
+	}
+	
+	c.quiet = map[string]*quietHolder{}
+	for _, chain := range c.Routes() {
+		if q, ok := c.QuietHours[chain.Name()]; ok {
+			c.quiet[chain.Name()] = q.Holder(chain, func(chain AlerterChain, a *AlertList) {
+				c.deliver([]AlerterChain{chain}, a)
+			})
+		}
+	}
+	
+	c.inhibitor = c.Inhibition.Start(c.Containers)
+	c.stormer = c.Storm.Start()
+	
+	if len(c.Escalations) != 0 {
+		c.escalator = newEscalator(c.Escalations, &c.Templates, c.Routes())
+	}
+	
+	if c.Digest.Wait != 0 {
+		c.digest = c.Digest.Start(func(a *AlertList) {
+			a.Send(c.Routes())
+		})
+	}
+	
+	if c.API.Address != "" {
+		c.API.Serve(c)
+	}
+	
 	AlertStarting(c, a)
-	AlertStopping(c, a)
+	AlertStopping(c)
 	
 	time.Sleep(time.Duration(c.Duration) * time.Millisecond)
 	
 	Monitor(c, a)
+	
+	Shutdown(c)
 }
\ No newline at end of file
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestCheckContainersRemoved(t *testing.T) {
+	defer func(r *alertRegistry) { activeAlerts = r }(activeAlerts)
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+	var removed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case atomic.LoadInt32(&removed) == 1:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "No such container: web"}`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Name": "/web", "State": {"Running": true},
+				"Config": {"Labels": {"com.docker.compose.project": "shop", "env": "prod"}}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	cli, err := client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.25", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := TemplateConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Conf{Containers: []Container{{Name: "web"}}, Templates: templates}
+	cnt := InitCheckers(c)
+
+	a := &AlertList{}
+	CheckContainers(cnt, cli, a)
+	if len(a.Alerts) != 0 {
+		t.Fatalf("expected no alert, got %+v", a.Alerts)
+	}
+
+	// the failure of the removed container has its last known project and labels
+	atomic.StoreInt32(&removed, 1)
+	a = &AlertList{}
+	CheckContainers(cnt, cli, a)
+	if len(a.Alerts) != 1 || a.Alerts[0].Check != CheckExist || a.Alerts[0].State != StateFailure {
+		t.Fatalf("expected the existence failure, got %+v", a.Alerts)
+	}
+	if a.Alerts[0].Project != "shop" || a.Alerts[0].Labels["env"] != "prod" {
+		t.Errorf("expected the labels of the removed container, got %+v", a.Alerts[0])
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+)
+
+// default topics of the MQTT alerter, the events topic receives the alerts which are not
+// related to a container check (starting, stopping, unknown errors)
+const (
+	mqttDefaultTopic      = "docker-alertd/{{.Host}}/{{.Container}}/{{.Check}}"
+	mqttDefaultEventTopic = "docker-alertd/{{.Host}}/events"
+)
+
+// MQTT contains all info needed to publish the alerts to a MQTT broker
+type MQTT struct {
+	Broker             string
+	ClientID           string
+	Username           string
+	Password           string
+	Topic              string
+	EventTopic         string
+	QoS                byte
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Valid returns an error if MQTT settings are invalid
+func (m MQTT) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(MQTT{}, m) {
+		return nil // assume that MQTT was omitted
+	}
+
+	if m.Broker == "" {
+		errString = append(errString, ErrMQTTBroker.Error())
+	}
+
+	if m.QoS > 2 {
+		errString = append(errString, ErrMQTTQoS.Error())
+	}
+
+	if (m.CertFile == "") != (m.KeyFile == "") {
+		errString = append(errString, ErrMQTTCertificate.Error())
+	}
+
+	if _, _, err := m.topics(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "mqtt settings validation fail")
+}
+
+// topics parses the topic templates
+func (m MQTT) topics() (*template.Template, *template.Template, error) {
+	topic, events := m.Topic, m.EventTopic
+	if topic == "" {
+		topic = mqttDefaultTopic
+	}
+	if events == "" {
+		events = mqttDefaultEventTopic
+	}
+
+	t, err := template.New("mqtt-topic").Parse(topic)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid mqtt topic")
+	}
+
+	e, err := template.New("mqtt-event-topic").Parse(events)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid mqtt event topic")
+	}
+
+	return t, e, nil
+}
+
+// tlsConfig returns the TLS configuration of the connection, nil if nothing is configured
+// and the default one of the client is used
+func (m MQTT) tlsConfig() (*tls.Config, error) {
+	if m.CAFile == "" && m.CertFile == "" && !m.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: m.InsecureSkipVerify}
+
+	if m.CAFile != "" {
+		pem, err := ioutil.ReadFile(m.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading mqtt ca file")
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in %s", m.CAFile)
+		}
+	}
+
+	if m.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(m.CertFile, m.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading mqtt client certificate")
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Messages returns the messages to publish for an alert. Check alerts are published as JSON
+// on their topic and their state (failing or ok) on the "state" sub topic, both retained so
+// that dashboards get the current health when they subscribe.
+func (m MQTT) Messages(a Alert, topic, events *template.Template) ([]MQTTMessage, error) {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding mqtt payload")
+	}
+
+	var name bytes.Buffer
+
+	if a.Container == "" || a.Check == "" {
+		if err := events.Execute(&name, a); err != nil {
+			return nil, errors.Wrap(err, "error executing mqtt event topic")
+		}
+
+		return []MQTTMessage{{Topic: name.String(), Payload: payload}}, nil
+	}
+
+	if err := topic.Execute(&name, a); err != nil {
+		return nil, errors.Wrap(err, "error executing mqtt topic")
+	}
+
+	state := "ok"
+	if a.State == StateFailure {
+		state = "failing"
+	}
+
+	return []MQTTMessage{
+		{Topic: name.String(), Payload: payload, Retained: true},
+		{Topic: name.String() + "/state", Payload: []byte(state), Retained: true},
+	}, nil
+}
+
+// MQTTMessage is a message published to the broker
+type MQTTMessage struct {
+	Topic    string
+	Payload  []byte
+	Retained bool
+}
+
+// Alert publishes the alerts to the broker
+func (m MQTT) Alert(ctx context.Context, a *AlertList) error {
+	topic, events, err := m.topics()
+	if err != nil {
+		return err
+	}
+
+	config, err := m.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	// a new connection is made for each batch, so the default id must be unique
+	clientID := m.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("docker-alertd-%s-%d", Config.Hostname, time.Now().UnixNano())
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.Broker).
+		SetClientID(clientID).
+		SetUsername(m.Username).
+		SetPassword(m.Password).
+		SetConnectTimeout(10 * time.Second).
+		SetAutoReconnect(false)
+	if config != nil {
+		opts.SetTLSConfig(config)
+	}
+
+	client := mqtt.NewClient(opts)
+
+	// the client has no context support, its operations wait until the deadline
+	wait := 15 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		wait = time.Until(deadline)
+	}
+
+	token := client.Connect()
+	if !token.WaitTimeout(wait) || token.Error() != nil {
+		return errors.Wrap(tokenError(token), "error connecting to mqtt broker")
+	}
+	defer client.Disconnect(250)
+
+	for _, alert := range a.Alerts {
+		messages, err := m.Messages(alert, topic, events)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range messages {
+			token := client.Publish(msg.Topic, m.QoS, msg.Retained, msg.Payload)
+			if !token.WaitTimeout(wait) || token.Error() != nil {
+				return errors.Wrapf(tokenError(token), "error publishing to %s", msg.Topic)
+			}
+		}
+	}
+
+	log.Println("sent alert to mqtt")
+	return nil
+}
+
+// tokenError returns the error of a token, or a timeout error if it has not completed
+func tokenError(t mqtt.Token) error {
+	if t.Error() != nil {
+		return t.Error()
+	}
+
+	return errors.New("timeout")
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBroker accepts MQTT connections and records the PUBLISH packets it receives
+type fakeBroker struct {
+	listener  net.Listener
+	published chan MQTTMessage
+	username  string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &fakeBroker{listener: l, published: make(chan MQTTMessage, 16)}
+	go b.serve()
+
+	return b
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			if body[7]&0x80 != 0 {
+				// skip the variable header and the client id to read the username
+				l := int(binary.BigEndian.Uint16(body[10:]))
+				u := 12 + l
+				b.username = string(body[u+2 : u+2+int(binary.BigEndian.Uint16(body[u:]))])
+			}
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			l := int(binary.BigEndian.Uint16(body))
+			msg := MQTTMessage{Topic: string(body[2 : 2+l]), Retained: header&0x01 != 0}
+			rest := body[2+l:]
+			if qos := (header >> 1) & 0x03; qos > 0 {
+				conn.Write([]byte{0x40, 0x02, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+			msg.Payload = rest
+			b.published <- msg
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func TestMQTTAlert(t *testing.T) {
+	b := newFakeBroker(t)
+	defer b.listener.Close()
+
+	m := MQTT{Broker: "tcp://" + b.listener.Addr().String(), Username: "alertd", Password: "secret", QoS: 1}
+	if err := m.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "CPU check failure", Container: "web", Check: CheckCPU,
+		State: StateFailure, Host: "host1"})
+	a.AddAlert(Alert{Title: "Starting", State: StateInfo, Host: "host1"})
+
+	if err := m.Alert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []MQTTMessage{
+		{Topic: "docker-alertd/host1/web/cpu", Retained: true},
+		{Topic: "docker-alertd/host1/web/cpu/state", Retained: true, Payload: []byte("failing")},
+		{Topic: "docker-alertd/host1/events"},
+	}
+
+	for _, e := range expected {
+		select {
+		case got := <-b.published:
+			if got.Topic != e.Topic || got.Retained != e.Retained {
+				t.Errorf("expected %s (retained %t), got %s (retained %t)", e.Topic, e.Retained,
+					got.Topic, got.Retained)
+			}
+			if e.Payload != nil && string(got.Payload) != string(e.Payload) {
+				t.Errorf("unexpected payload on %s: %s", got.Topic, got.Payload)
+			}
+			if e.Payload == nil && !strings.Contains(string(got.Payload), `"host":"host1"`) {
+				t.Errorf("expected a JSON alert on %s, got %s", got.Topic, got.Payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message on %s not published", e.Topic)
+		}
+	}
+
+	if b.username != "alertd" {
+		t.Errorf("expected username alertd, got %q", b.username)
+	}
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// default queue settings
+const (
+	queueDefaultMaxAge = 24
+	queuePollInterval  = 100 * time.Millisecond
+)
+
+// Queue contains the settings of the on-disk queue of the alerts. When a path is set, the
+// alerts are stored before being sent and removed once delivered, so that they survive
+// restarts and network outages. Each alerter has its own queue, sent in order, and the
+// alerts older than MaxAge hours are dropped.
+type Queue struct {
+	Path   string
+	MaxAge uint64
+}
+
+// queueEntry is an alert list waiting in the queue of an alerter
+type queueEntry struct {
+	Queued time.Time  `json:"queued"`
+	Alerts *AlertList `json:"alerts"`
+}
+
+// alertQueue sends the queued alerts, with a worker for each alerter chain
+type alertQueue struct {
+	db       *bolt.DB
+	delivery Delivery
+	maxAge   time.Duration
+	notify   map[string]chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+}
+
+// Open opens the queue database and starts sending the alerts left by a previous run, the
+// queues of the alerters which are not configured anymore are discarded. The queue of a
+// fallback chain is the one of its first alerter.
+func (q Queue) Open(routes []AlerterChain, d Delivery) (*alertQueue, error) {
+	db, err := bolt.Open(q.Path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the queue %s", q.Path)
+	}
+
+	maxAge := time.Duration(q.MaxAge) * time.Hour
+	if q.MaxAge == 0 {
+		maxAge = queueDefaultMaxAge * time.Hour
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aq := &alertQueue{
+		db:       db,
+		delivery: d,
+		maxAge:   maxAge,
+		notify:   map[string]chan struct{}{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	for _, chain := range routes {
+		aq.notify[chain.Name()] = make(chan struct{}, 1)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		unknown := [][]byte{}
+		err := tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			if _, ok := aq.notify[string(name)]; !ok {
+				unknown = append(unknown, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, name := range unknown {
+			log.Printf("discarding %d queued alerts of %s which is not configured\n",
+				tx.Bucket(name).Stats().KeyN, name)
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		for name := range aq.notify {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+
+			if n := bucket.Stats().KeyN; n != 0 {
+				log.Printf("%d alerts queued for %s\n", n, name)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "error initializing the queue")
+	}
+
+	for _, chain := range routes {
+		aq.workers.Add(1)
+		go aq.work(chain)
+	}
+
+	return aq, nil
+}
+
+// Enqueue stores the alerts in the queues of the given alerter chains, or of every chain
+// when none is given
+func (q *alertQueue) Enqueue(a *AlertList, names ...string) error {
+	value, err := json.Marshal(queueEntry{Queued: time.Now(), Alerts: a})
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		for name := range q.notify {
+			names = append(names, name)
+		}
+	}
+
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range names {
+			bucket := tx.Bucket([]byte(name))
+
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, seq)
+
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		select {
+		case q.notify[name] <- struct{}{}:
+		default: // the worker has already been notified
+		}
+	}
+
+	return nil
+}
+
+// first returns the oldest entry of the queue of an alerter, its key is nil if the queue
+// is empty
+func (q *alertQueue) first(name string) ([]byte, queueEntry, error) {
+	var key []byte
+	var entry queueEntry
+
+	err := q.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket([]byte(name)).Cursor().First()
+		if k == nil {
+			return nil
+		}
+
+		key = append([]byte{}, k...)
+		return json.Unmarshal(v, &entry)
+	})
+
+	return key, entry, err
+}
+
+// remove deletes an entry from the queue of an alerter
+func (q *alertQueue) remove(name string, key []byte) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(name)).Delete(key)
+	})
+}
+
+// work sends the queue of an alerter chain in order. An entry which fails with a temporary
+// error stays at the head of the queue and is retried after a backoff, until it expires.
+func (q *alertQueue) work(chain AlerterChain) {
+	defer q.workers.Done()
+
+	name := chain.Name()
+	retries := intOr(q.delivery.Retries, deliveryDefaultRetries)
+
+	for {
+		key, entry, err := q.first(name)
+		switch {
+		case err != nil && key != nil:
+			// the entry can not be decoded, it would block the queue
+			log.Println(errors.Wrapf(err, "error decoding an alert queued for %s", name))
+			q.remove(name, key)
+			continue
+
+		case err != nil:
+			log.Println(errors.Wrapf(err, "error reading the queue of %s", name))
+			if sleep(q.ctx, time.Second) != nil {
+				return
+			}
+			continue
+
+		case key == nil:
+			select {
+			case <-q.notify[name]:
+				continue
+			case <-q.ctx.Done():
+				return
+			}
+
+		case time.Since(entry.Queued) > q.maxAge:
+			err := errors.Errorf("queued since %s", entry.Queued.Format(time.RFC1123))
+			logDropped(name, err, entry.Alerts)
+			q.delivery.history.Delivered(name, entry.Alerts, err)
+			q.remove(name, key)
+			continue
+		}
+
+		err = q.delivery.retryChain(q.ctx, chain, entry.Alerts)
+		if q.ctx.Err() != nil {
+			return // shutting down, the entry is sent on the next start
+		}
+
+		if err != nil && temporary(err) {
+			wait := q.delivery.backoff(retries)
+			log.Printf("error sending alert to %s, keeping it queued and retrying in %s: %s\n",
+				name, wait.Round(time.Millisecond), err)
+
+			if sleep(q.ctx, wait) != nil {
+				return
+			}
+			continue
+		}
+
+		if err != nil {
+			logDropped(name, err, entry.Alerts)
+		}
+		q.delivery.history.Delivered(name, entry.Alerts, err)
+
+		if err := q.remove(name, key); err != nil {
+			log.Println(errors.Wrapf(err, "error removing alert from the queue of %s", name))
+		}
+	}
+}
+
+// empty returns true if every queue is empty
+func (q *alertQueue) empty() bool {
+	empty := true
+
+	q.db.View(func(tx *bolt.Tx) error {
+		for name := range q.notify {
+			if k, _ := tx.Bucket([]byte(name)).Cursor().First(); k != nil {
+				empty = false
+			}
+		}
+		return nil
+	})
+
+	return empty
+}
+
+// Close waits for the queues to be sent until the timeout, then stops the workers and
+// closes the database, the remaining alerts are sent on the next start
+func (q *alertQueue) Close(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !q.empty() && time.Now().Before(deadline) {
+		time.Sleep(queuePollInterval)
+	}
+
+	q.cancel()
+	q.workers.Wait()
+
+	return q.db.Close()
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingAlerter records the titles of the alerts it sends, or fails with a 502
+type recordingAlerter struct {
+	sync.Mutex
+	down bool
+	sent []string
+	last *AlertList
+}
+
+func (r *recordingAlerter) Valid() error { return nil }
+
+func (r *recordingAlerter) Alert(ctx context.Context, a *AlertList) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.down {
+		return &StatusError{Service: "test", Code: http.StatusBadGateway, Status: "502 Bad Gateway"}
+	}
+
+	r.sent = append(r.sent, strings.TrimSpace(a.Title()))
+	r.last = a
+	return nil
+}
+
+func (r *recordingAlerter) titles() string {
+	r.Lock()
+	defer r.Unlock()
+
+	return strings.Join(r.sent, ",")
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+	sleep = func(ctx context.Context, d time.Duration) error {
+		select {
+		case <-time.After(10 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	q := Queue{Path: filepath.Join(t.TempDir(), "queue.db")}
+	down := &recordingAlerter{down: true}
+
+	aq, err := q.Open([]AlerterChain{{down}}, Delivery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"first", "second", "third"} {
+		a := &AlertList{}
+		a.AddAlert(Alert{Title: title, State: StateFailure})
+		if err := aq.Enqueue(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := aq.Close(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	up := &recordingAlerter{}
+	aq, err = q.Open([]AlerterChain{{up}}, Delivery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer aq.Close(0)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !aq.empty() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if titles := up.titles(); titles != "first,second,third" {
+		t.Errorf("expected the queued alerts in order, got %q", titles)
+	}
+}
+
+func TestQueueDropsExpired(t *testing.T) {
+	q := Queue{Path: filepath.Join(t.TempDir(), "queue.db")}
+	r := &recordingAlerter{}
+
+	aq, err := q.Open([]AlerterChain{{r}}, Delivery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer aq.Close(0)
+
+	// the entry is older than the max age once it reaches the worker
+	aq.maxAge = -time.Second
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Title: "old", State: StateFailure})
+	if err := aq.Enqueue(a); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !aq.empty() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if titles := r.titles(); titles != "" || !aq.empty() {
+		t.Errorf("expected the expired alert to be dropped, got %q", titles)
+	}
+}
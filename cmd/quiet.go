@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// severities orders the severities of the alerts, an unknown severity is critical
+var severities = map[string]int{
+	"":         0,
+	"info":     0,
+	"warning":  1,
+	"error":    2,
+	"critical": 3,
+}
+
+// severityRank returns the rank of a severity
+func severityRank(s string) int {
+	if rank, ok := severities[s]; ok {
+		return rank
+	}
+
+	return severities["critical"]
+}
+
+// QuietHours contains the quiet hours of an alerter, from Start to End ("22:00" to "07:00")
+// in Timezone (default local). During the quiet hours, the alerts with a severity below
+// Severity (default critical) are held and sent at once when the quiet hours end, without
+// the failures which recovered in the meantime. The quiet hours of an alerter also apply
+// to its fallbacks.
+type QuietHours struct {
+	Start    string
+	End      string
+	Timezone string
+	Severity string
+}
+
+// parseClock returns the minutes since midnight of a "15:04" time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.Errorf("invalid time %q, expected hh:mm", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Valid returns an error if quiet hours settings are invalid
+func (q QuietHours) Valid() error {
+	errString := []string{}
+
+	start, errStart := parseClock(q.Start)
+	if errStart != nil {
+		errString = append(errString, errStart.Error())
+	}
+
+	end, errEnd := parseClock(q.End)
+	if errEnd != nil {
+		errString = append(errString, errEnd.Error())
+	}
+
+	if errStart == nil && errEnd == nil && start == end {
+		errString = append(errString, ErrQuietHoursEmpty.Error())
+	}
+
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if _, ok := severities[q.Severity]; !ok {
+		errString = append(errString, errors.Wrap(ErrQuietHoursSeverity, q.Severity).Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "quiet hours settings validation fail")
+}
+
+// Until returns the end of the quiet hours containing t, or the zero time if t is outside
+// of the quiet hours
+func (q QuietHours) Until(t time.Time) time.Time {
+	start, _ := parseClock(q.Start)
+	end, _ := parseClock(q.End)
+
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return time.Time{}
+	}
+
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+
+	quiet := now >= start && now < end
+	if start > end {
+		quiet = now >= start || now < end
+	}
+	if !quiet {
+		return time.Time{}
+	}
+
+	until := time.Date(t.Year(), t.Month(), t.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(t) {
+		until = time.Date(t.Year(), t.Month(), t.Day()+1, end/60, end%60, 0, 0, loc)
+	}
+
+	return until
+}
+
+// quietHolder holds the alerts of an alerter chain during its quiet hours
+type quietHolder struct {
+	sync.Mutex
+	settings QuietHours
+	chain    AlerterChain
+	held     []Alert
+	timer    *time.Timer
+	send     func(AlerterChain, *AlertList)
+}
+
+// Holder returns the holder of the quiet hours of a chain, the held alerts are given to send
+// when the quiet hours end
+func (q QuietHours) Holder(chain AlerterChain, send func(AlerterChain, *AlertList)) *quietHolder {
+	return &quietHolder{settings: q, chain: chain, send: send}
+}
+
+// Hold holds the alerts below the severity threshold during the quiet hours and returns
+// the ones to send right away
+func (h *quietHolder) Hold(a *AlertList) *AlertList {
+	until := h.settings.Until(time.Now())
+	if until.IsZero() {
+		return a
+	}
+
+	threshold := severityRank(h.settings.Severity)
+	if h.settings.Severity == "" {
+		threshold = severityRank(DefaultSeverity)
+	}
+
+	list := &AlertList{Alerts: []Alert{}, Fallback: a.Fallback}
+
+	h.Lock()
+	defer h.Unlock()
+
+	for _, alert := range a.Alerts {
+		if severityRank(alert.Severity) >= threshold {
+			list.Alerts = append(list.Alerts, alert)
+			continue
+		}
+
+		log.Printf("holding alert for %s until %s: %s\n", h.chain.Name(),
+			until.Format("15:04 MST"), alert.Dump())
+		h.held = append(h.held, alert)
+	}
+
+	if len(h.held) != 0 && h.timer == nil {
+		h.timer = time.AfterFunc(time.Until(until), h.Flush)
+	}
+
+	return list
+}
+
+// Flush sends the held alerts, a failure followed by the recovery of its check is dropped
+// with its recovery
+func (h *quietHolder) Flush() {
+	h.Lock()
+	held := h.held
+	h.held = nil
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	h.Unlock()
+
+	list := &AlertList{Alerts: resolvedPairs(held)}
+	if dropped := len(held) - len(list.Alerts); dropped != 0 {
+		log.Printf("dropping %d held alerts of %s which recovered\n", dropped, h.chain.Name())
+	}
+
+	if list.ShouldSend() {
+		h.send(h.chain, list)
+	}
+}
+
+// resolvedPairs returns the alerts without the failures followed by the recovery of their
+// check, nor these recoveries. The reminders and escalations of a failure are dropped with
+// its recovery, which is still sent when the failure itself was not held.
+func resolvedPairs(alerts []Alert) []Alert {
+	dropped := make([]bool, len(alerts))
+	failures := map[string][]int{}
+	held := map[string]bool{}
+
+	for i, alert := range alerts {
+		switch alert.State {
+		case StateFailure:
+			failures[alert.Key()] = append(failures[alert.Key()], i)
+			held[alert.Key()] = held[alert.Key()] || !alert.FollowUp
+		case StateRecovery:
+			for _, f := range failures[alert.Key()] {
+				dropped[f] = true
+			}
+			dropped[i] = held[alert.Key()]
+			delete(failures, alert.Key())
+			delete(held, alert.Key())
+		}
+	}
+
+	kept := []Alert{}
+	for i, alert := range alerts {
+		if !dropped[i] {
+			kept = append(kept, alert)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursUntil(t *testing.T) {
+	q := QuietHours{Start: "22:00", End: "07:00", Timezone: "Europe/Paris"}
+
+	cases := []struct {
+		time  string
+		until string
+	}{
+		{"2020-06-01T23:30:00+02:00", "2020-06-02T07:00:00+02:00"},
+		{"2020-06-02T03:00:00+02:00", "2020-06-02T07:00:00+02:00"},
+		{"2020-06-02T07:00:00+02:00", ""},
+		{"2020-06-02T12:00:00+02:00", ""},
+		{"2020-06-01T20:30:00Z", "2020-06-02T07:00:00+02:00"}, // 22:30 in Paris
+	}
+
+	for _, c := range cases {
+		tm, _ := time.Parse(time.RFC3339, c.time)
+		until := q.Until(tm)
+
+		switch {
+		case c.until == "" && !until.IsZero():
+			t.Errorf("%s: expected no quiet hours, got %s", c.time, until)
+		case c.until != "":
+			expected, _ := time.Parse(time.RFC3339, c.until)
+			if !until.Equal(expected) {
+				t.Errorf("%s: expected quiet hours until %s, got %s", c.time, expected, until)
+			}
+		}
+	}
+
+	if err := (QuietHours{Start: "7:00", End: "7:00", Severity: "loud"}).Valid(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQuietHoursHold(t *testing.T) {
+	now := time.Now().UTC()
+	q := QuietHours{
+		Start:    now.Add(-time.Hour).Format("15:04"),
+		End:      now.Add(time.Hour).Format("15:04"),
+		Timezone: "UTC",
+	}
+
+	var sent *AlertList
+	h := q.Holder(AlerterChain{&recordingAlerter{}}, func(c AlerterChain, a *AlertList) {
+		sent = a
+	})
+
+	a := &AlertList{}
+	a.AddAlert(Alert{Container: "db", Check: CheckRunning, State: StateFailure, Severity: "critical"})
+	a.AddAlert(Alert{Container: "web", Check: CheckCPU, State: StateFailure, Severity: "warning"})
+	a.AddAlert(Alert{Container: "cache", Check: CheckMemory, State: StateFailure, Severity: "warning"})
+
+	if l := h.Hold(a); len(l.Alerts) != 1 || l.Alerts[0].Container != "db" {
+		t.Fatalf("expected only the critical alert to be sent, got %+v", l.Alerts)
+	}
+
+	// the reminders of the failure are dropped with it
+	for i := 0; i < 2; i++ {
+		a = &AlertList{}
+		a.AddAlert(Alert{Container: "web", Check: CheckCPU, State: StateFailure, Severity: "warning",
+			Title: "Reminder: CPU check failure", FollowUp: true})
+		h.Hold(a)
+	}
+
+	a = &AlertList{}
+	a.AddAlert(Alert{Container: "web", Check: CheckCPU, State: StateRecovery, Severity: "warning"})
+	h.Hold(a)
+
+	h.Flush()
+	if sent == nil || len(sent.Alerts) != 1 || sent.Alerts[0].Container != "cache" {
+		t.Fatalf("expected the recovered pair to be dropped, got %+v", sent)
+	}
+
+	// the recovery of a failure sent before the quiet hours is sent without its reminders
+	for _, state := range []AlertState{StateFailure, StateRecovery} {
+		a = &AlertList{}
+		a.AddAlert(Alert{Container: "cache", Check: CheckMemory, State: state, Severity: "warning",
+			FollowUp: state == StateFailure})
+		h.Hold(a)
+	}
+
+	h.Flush()
+	if len(sent.Alerts) != 1 || sent.Alerts[0].State != StateRecovery {
+		t.Fatalf("expected only the recovery to be sent, got %+v", sent.Alerts)
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit is the token bucket of an alerter, it sends at most Burst deliveries at once and
+// Rate deliveries per minute on average
+type RateLimit struct {
+	Rate  float64
+	Burst uint64
+}
+
+// tokenBucket is the state of a rate limit
+type tokenBucket struct {
+	sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(r RateLimit) *tokenBucket {
+	burst := float64(r.Burst)
+	if burst == 0 {
+		burst = 1
+	}
+
+	return &tokenBucket{rate: r.Rate / 60, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Allow takes a token if one is available
+func (b *tokenBucket) Allow() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
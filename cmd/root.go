@@ -109,6 +109,8 @@ type Container struct {
 	MinProcs        *uint64
 	ExpectedRunning *bool
 	Delay			*uint64
+	Severity		string
+	DependsOn		[]string
 }
 
 // Conf struct that combines containers and email settings structs
@@ -116,11 +118,44 @@ type Conf struct {
 	Containers []Container
 	Email      Email
 	Slack      Slack
+	Matrix     Matrix
+	Mattermost Mattermost
 	Pushover   Pushover
 	Pushbullet Pushbullet
+	Alertmanager Alertmanager
+	Syslog     Syslog
+	Journald   Journald
+	Exec       Exec
+	MQTT       MQTT
+	Gotify     Gotify
+	Ntfy       Ntfy
+	SMS        SMS
+	Hostname   string
+	Daemon     Daemon
+	State      State
+	History    History
+	history    *historyStore
 	Iterations uint64
 	Duration   uint64
+	Reminder   uint64
 	Alerters   []Alerter
+	Fallbacks  []string
+	QuietHours map[string]QuietHours
+	Escalations []Escalation
+	Inhibition Inhibition
+	inhibitor  *inhibitor
+	escalator  *escalator
+	quiet      map[string]*quietHolder
+	Digest     Digest
+	digest     *digester
+	Storm      Storm
+	stormer    *stormer
+	Delivery   Delivery
+	Queue      Queue
+	queue      *alertQueue
+	Silences   Silences
+	silencer   *silencer
+	API        API
 	Templates  TemplateConfig
 }
 
@@ -149,12 +184,43 @@ func (c *Conf) ValidateSlackSettings() error {
 	case err != nil:
 		return err
 	default:
+		c.Slack.threads = &slackThreads{threads: map[string]slackThread{}, posted: map[string]slackPosted{}}
 		c.Alerters = append(c.Alerters, c.Slack)
 		log.Println("slack alerts active")
 		return nil
 	}
 }
 
+// ValidateMatrixSettings validates matrix settings and adds it to the alerters
+func (c *Conf) ValidateMatrixSettings() error {
+	err := c.Matrix.Valid()
+	switch {
+	case reflect.DeepEqual(Matrix{}, c.Matrix):
+		return nil // assume that matrix was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Matrix)
+		log.Println("matrix alerts active")
+		return nil
+	}
+}
+
+// ValidateMattermostSettings validates mattermost settings and adds it to the alerters
+func (c *Conf) ValidateMattermostSettings() error {
+	err := c.Mattermost.Valid()
+	switch {
+	case reflect.DeepEqual(Mattermost{}, c.Mattermost):
+		return nil // assume that mattermost was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Mattermost)
+		log.Println("mattermost alerts active")
+		return nil
+	}
+}
+
 // ValidatePushoverSettings validates pushover settings and adds it to the alerters
 func (c *Conf) ValidatePushoverSettings() error {
 	err := c.Pushover.Valid()
@@ -164,6 +230,7 @@ func (c *Conf) ValidatePushoverSettings() error {
 	case err != nil:
 		return err
 	default:
+		c.Pushover.receipts = &pushoverReceipts{receipts: map[string]string{}}
 		c.Alerters = append(c.Alerters, c.Pushover)
 		log.Println("pushover alerts active")
 		return nil
@@ -185,6 +252,267 @@ func (c *Conf) ValidatePushbulletSettings() error {
 	}
 }
 
+// ValidateAlertmanagerSettings validates alertmanager settings and adds it to the alerters
+func (c *Conf) ValidateAlertmanagerSettings() error {
+	err := c.Alertmanager.Valid()
+	switch {
+	case reflect.DeepEqual(Alertmanager{}, c.Alertmanager):
+		return nil // assume that alertmanager was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alertmanager.active = &alertmanagerActive{alerts: map[string]alertmanagerAlert{}}
+		c.Alerters = append(c.Alerters, c.Alertmanager)
+		log.Println("alertmanager alerts active")
+		return nil
+	}
+}
+
+// ValidateSyslogSettings validates syslog settings and adds it to the alerters
+func (c *Conf) ValidateSyslogSettings() error {
+	err := c.Syslog.Valid()
+	switch {
+	case reflect.DeepEqual(Syslog{}, c.Syslog) && !viper.IsSet("syslog"):
+		return nil // assume that syslog was omitted, a block left at its defaults is set
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Syslog)
+		log.Println("syslog alerts active")
+		return nil
+	}
+}
+
+// ValidateJournaldSettings validates journald settings and adds it to the alerters
+func (c *Conf) ValidateJournaldSettings() error {
+	err := c.Journald.Valid()
+	switch {
+	case reflect.DeepEqual(Journald{}, c.Journald) && !viper.IsSet("journald"):
+		return nil // assume that journald was omitted, a block left at its defaults is set
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Journald)
+		log.Println("journald alerts active")
+		return nil
+	}
+}
+
+// ValidateExecSettings validates exec settings and adds it to the alerters
+func (c *Conf) ValidateExecSettings() error {
+	err := c.Exec.Valid()
+	switch {
+	case reflect.DeepEqual(Exec{}, c.Exec):
+		return nil // assume that exec was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Exec)
+		log.Println("exec alerts active")
+		return nil
+	}
+}
+
+// ValidateMQTTSettings validates MQTT settings and adds it to the alerters
+func (c *Conf) ValidateMQTTSettings() error {
+	err := c.MQTT.Valid()
+	switch {
+	case reflect.DeepEqual(MQTT{}, c.MQTT):
+		return nil // assume that MQTT was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.MQTT)
+		log.Println("mqtt alerts active")
+		return nil
+	}
+}
+
+// ValidateGotifySettings validates Gotify settings and adds it to the alerters
+func (c *Conf) ValidateGotifySettings() error {
+	err := c.Gotify.Valid()
+	switch {
+	case reflect.DeepEqual(Gotify{}, c.Gotify):
+		return nil // assume that Gotify was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Gotify)
+		log.Println("gotify alerts active")
+		return nil
+	}
+}
+
+// ValidateNtfySettings validates ntfy settings and adds it to the alerters
+func (c *Conf) ValidateNtfySettings() error {
+	err := c.Ntfy.Valid()
+	switch {
+	case reflect.DeepEqual(Ntfy{}, c.Ntfy):
+		return nil // assume that ntfy was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Ntfy)
+		log.Println("ntfy alerts active")
+		return nil
+	}
+}
+
+// ValidateSMSSettings validates SMS settings and adds it to the alerters
+func (c *Conf) ValidateSMSSettings() error {
+	err := c.SMS.Valid()
+	switch {
+	case reflect.DeepEqual(SMS{}, c.SMS):
+		return nil // assume that SMS was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.SMS.sent = &smsDeliveries{messages: map[string]smsDelivery{}}
+		c.Alerters = append(c.Alerters, c.SMS)
+		log.Println("sms alerts active")
+		return nil
+	}
+}
+
+// ValidateFallbackSettings validates the fallback chains, they must link at least two
+// configured alerters and an alerter can only be part of one chain
+func (c *Conf) ValidateFallbackSettings() error {
+	errString := []string{}
+	
+	configured := map[string]bool{}
+	for _, b := range c.Alerters {
+		configured[alerterName(b)] = true
+	}
+	
+	chained := map[string]bool{}
+	for _, fallback := range c.Fallbacks {
+		names := parseFallback(fallback)
+		if len(names) < 2 {
+			errString = append(errString, errors.Wrap(ErrFallbackTooShort, fallback).Error())
+		}
+		
+		for _, name := range names {
+			switch {
+			case !configured[name]:
+				errString = append(errString, errors.Wrap(ErrFallbackUnknown, name).Error())
+			case chained[name]:
+				errString = append(errString, errors.Wrap(ErrFallbackDuplicate, name).Error())
+			}
+			chained[name] = true
+		}
+	}
+	
+	if len(errString) == 0 {
+		for _, fallback := range c.Fallbacks {
+			names := parseFallback(fallback)
+			log.Printf("fallback alerts active, only %s receives the alerts directly: %s\n",
+				names[0], strings.Join(names, " -> "))
+		}
+		return nil
+	}
+	
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+	
+	return errors.Wrap(err, "fallback settings validation fail")
+}
+
+// ValidateQuietHoursSettings calls valid on the quiet hours of each alerter, only the first
+// alerter of a fallback chain can have quiet hours
+func (c *Conf) ValidateQuietHoursSettings() error {
+	errString := []string{}
+	
+	heads := map[string]bool{}
+	for _, chain := range c.Routes() {
+		heads[chain.Name()] = true
+	}
+	
+	configured := map[string]bool{}
+	for _, b := range c.Alerters {
+		configured[alerterName(b)] = true
+	}
+	
+	for name, q := range c.QuietHours {
+		switch {
+		case !configured[name]:
+			errString = append(errString, errors.Wrap(ErrQuietHoursUnknown, name).Error())
+		case !heads[name]:
+			errString = append(errString, errors.Wrap(ErrQuietHoursFallback, name).Error())
+		}
+		
+		if err := q.Valid(); err != nil {
+			errString = append(errString, errors.Wrap(err, name).Error())
+		}
+	}
+	
+	if len(errString) == 0 {
+		return nil
+	}
+	
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+	
+	return errors.Wrap(err, "quiet hours settings validation fail")
+}
+
+// ValidateEscalationSettings calls valid on each escalation policy
+func (c *Conf) ValidateEscalationSettings() error {
+	errString := []string{}
+	
+	heads := map[string]bool{}
+	for _, chain := range c.Routes() {
+		heads[chain.Name()] = true
+	}
+	
+	for _, e := range c.Escalations {
+		if err := e.Valid(heads); err != nil {
+			errString = append(errString, err.Error())
+		}
+	}
+	
+	if len(errString) == 0 {
+		return nil
+	}
+	
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+	
+	return errors.Wrap(err, "escalations settings validation fail")
+}
+
+// ValidateInhibitionSettings calls valid on the inhibition settings and checks that the
+// dependencies of the containers are monitored
+func (c *Conf) ValidateInhibitionSettings() error {
+	errString := []string{}
+	
+	if err := c.Inhibition.Valid(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	monitored := map[string]bool{}
+	for _, container := range c.Containers {
+		monitored[container.Name] = true
+	}
+	
+	for _, container := range c.Containers {
+		for _, parent := range container.DependsOn {
+			if !monitored[parent] || parent == container.Name {
+				errString = append(errString,
+					errors.Wrapf(ErrDependsOnUnknown, "%s depends on %s", container.Name, parent).Error())
+			}
+		}
+	}
+	
+	if len(errString) == 0 {
+		return nil
+	}
+	
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+	
+	return errors.Wrap(err, "inhibition settings validation fail")
+}
+
 func (c *Conf) ValidateTemplatesSettings() error {
 	var err error
 	
@@ -219,6 +547,14 @@ func (c *Conf) Validate() error {
 		errString = append(errString, err.Error())
 	}
 
+	if err := c.ValidateMatrixSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateMattermostSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
 	if err := c.ValidatePushoverSettings(); err != nil {
 		errString = append(errString, err.Error())
 	}
@@ -227,10 +563,80 @@ func (c *Conf) Validate() error {
 		errString = append(errString, err.Error())
 	}
 	
+	if err := c.ValidateAlertmanagerSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateSyslogSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateJournaldSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateExecSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateMQTTSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateGotifySettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateNtfySettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateSMSSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateFallbackSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateQuietHoursSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateEscalationSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.ValidateInhibitionSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.Digest.Valid(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
+	if err := c.Delivery.Valid(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	c.Delivery = c.Delivery.WithRateLimits()
+	
+	if err := c.Silences.Valid(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	c.silencer = c.Silences.Start()
+	
+	if err := c.API.Valid(); err != nil {
+		errString = append(errString, err.Error())
+	}
+	
 	if err := c.ValidateTemplatesSettings(); err != nil {
 		errString = append(errString, err.Error())
 	}
 	
+	if c.Hostname == "" {
+		c.Hostname, _ = os.Hostname()
+	}
+	
 	// if the length of the string of errors is 0 then everything has completed
 	// successfully and everything is valid.
 	if len(errString) == 0 {
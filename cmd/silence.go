@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	silenceFlags    Silence
+	silenceStart    string
+	silenceEnd      string
+	silenceDuration time.Duration
+)
+
+// silenceCmd represents the silence command
+var silenceCmd = &cobra.Command{
+	Use:   "silence",
+	Short: "manage the silences of the alerts",
+	Long: `Add, list and remove the ad-hoc silences stored in the silences path of the config
+file, the running daemon picks the changes up without restarting.`,
+}
+
+// silenceAddCmd represents the silence add command
+var silenceAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "silence the matching alerts",
+	Long: `Silence the alerts matching the container, label and check from the start (default
+now) until the end or for the duration, e.g.:
+
+docker-alertd silence add --container "web-*" --for 30m --comment "deploy"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		s := silenceFlags
+
+		var err error
+		if silenceStart != "" {
+			if s.Start, err = time.Parse(time.RFC3339, silenceStart); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if s.Start.IsZero() {
+			s.Start = time.Now()
+		}
+
+		switch {
+		case silenceEnd != "":
+			if s.End, err = time.Parse(time.RFC3339, silenceEnd); err != nil {
+				log.Fatal(err)
+			}
+		case silenceDuration != 0:
+			s.End = s.Start.Add(silenceDuration)
+		}
+
+		if s.CreatedBy == "" {
+			s.CreatedBy = os.Getenv("USER")
+		}
+
+		s, err = AddSilence(Config.Silences.Path, s)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(s.ID)
+	},
+}
+
+// silenceListCmd represents the silence list command
+var silenceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the silences",
+	Run: func(cmd *cobra.Command, args []string) {
+		silences, err := loadSilences(Config.Silences.Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCONTAINER\tLABEL\tCHECK\tACTIVE\tCREATED BY\tCOMMENT")
+
+		for _, s := range append(Config.Silences.Static, silences...) {
+			active := fmt.Sprintf("%s - %s", s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+			if s.Schedule != "" {
+				active = fmt.Sprintf("%s for %dm", s.Schedule, s.Duration)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Container, s.Label, s.Check,
+				active, s.CreatedBy, s.Comment)
+		}
+
+		w.Flush()
+	},
+}
+
+// silenceRemoveCmd represents the silence remove command
+var silenceRemoveCmd = &cobra.Command{
+	Use:   "remove ID",
+	Short: "remove a silence",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RemoveSilence(Config.Silences.Path, args[0]); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(silenceCmd)
+	silenceCmd.AddCommand(silenceAddCmd, silenceListCmd, silenceRemoveCmd)
+
+	silenceAddCmd.Flags().StringVar(&silenceFlags.Container, "container", "",
+		"container name or pattern, e.g. web-*")
+	silenceAddCmd.Flags().StringVar(&silenceFlags.Label, "label", "",
+		"container label, key or key=value")
+	silenceAddCmd.Flags().StringVar(&silenceFlags.Check, "check", "",
+		"check name (exist, running, cpu, memory or min-pid)")
+	silenceAddCmd.Flags().StringVar(&silenceStart, "start", "",
+		"start of the silence in RFC3339 format (default now)")
+	silenceAddCmd.Flags().StringVar(&silenceEnd, "end", "",
+		"end of the silence in RFC3339 format")
+	silenceAddCmd.Flags().DurationVar(&silenceDuration, "for", 0,
+		"duration of the silence, e.g. 2h30m")
+	silenceAddCmd.Flags().StringVar(&silenceFlags.Comment, "comment", "",
+		"reason of the silence")
+	silenceAddCmd.Flags().StringVar(&silenceFlags.CreatedBy, "created-by", "",
+		"author of the silence (default $USER)")
+}
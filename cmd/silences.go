@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Silence suppresses the delivery of the alerts it matches, the checks still run. The
+// container is a pattern like "web-*", the label is "key" or "key=value" and an empty
+// matcher matches every alert. Ad-hoc silences are active from Start to End, the static ones
+// of the configuration file during the Duration minutes following each run of their cron
+// Schedule, in their Timezone (default local).
+type Silence struct {
+	ID        string    `json:"id,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Label     string    `json:"label,omitempty"`
+	Check     string    `json:"check,omitempty"`
+	Start     time.Time `json:"start,omitempty"`
+	End       time.Time `json:"end,omitempty"`
+	Schedule  string    `json:"schedule,omitempty"`
+	Duration  uint64    `json:"duration,omitempty"`
+	Timezone  string    `json:"timezone,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// Valid returns an error if the silence is invalid
+func (s Silence) Valid() error {
+	errString := []string{}
+
+	if _, err := path.Match(s.Container, ""); err != nil {
+		errString = append(errString, errors.Wrapf(err, "invalid container %q", s.Container).Error())
+	}
+
+	switch {
+	case s.Schedule != "":
+		if _, err := parseCron(s.Schedule); err != nil {
+			errString = append(errString, err.Error())
+		}
+		if s.Duration == 0 {
+			errString = append(errString, ErrSilenceDuration.Error())
+		}
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			errString = append(errString, err.Error())
+		}
+	case s.End.IsZero() || !s.End.After(s.Start):
+		errString = append(errString, ErrSilenceEnd.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "silence validation fail")
+}
+
+// Active returns true if the silence is active at the given time
+func (s Silence) Active(t time.Time) bool {
+	if s.Schedule == "" {
+		return !t.Before(s.Start) && t.Before(s.End)
+	}
+
+	c, err := parseCron(s.Schedule)
+	if err != nil {
+		return false
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+
+	return c.Active(t.In(loc), time.Duration(s.Duration)*time.Minute)
+}
+
+// Matches returns true if the silence is active and matches the alert
+func (s Silence) Matches(a Alert, t time.Time) bool {
+	if s.Check != "" && s.Check != a.Check {
+		return false
+	}
+
+	if s.Container != "" {
+		if ok, _ := path.Match(s.Container, a.Container); !ok {
+			return false
+		}
+	}
+
+	if s.Label != "" {
+		kv := strings.SplitN(s.Label, "=", 2)
+		value, ok := a.Labels[kv[0]]
+		if !ok || (len(kv) == 2 && kv[1] != value) {
+			return false
+		}
+	}
+
+	return s.Active(t)
+}
+
+// Silences contains the static silences and the path of the file of the ad-hoc silences,
+// added with the silence command or the API
+type Silences struct {
+	Path   string
+	Static []Silence
+}
+
+// Valid returns an error if a static silence is invalid
+func (s Silences) Valid() error {
+	errString := []string{}
+
+	for _, silence := range s.Static {
+		if silence.Schedule == "" {
+			errString = append(errString, ErrSilenceSchedule.Error())
+		} else if err := silence.Valid(); err != nil {
+			errString = append(errString, err.Error())
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "silences settings validation fail")
+}
+
+// silencer filters the silenced alerts, the ad-hoc silences are reloaded when their file
+// changes
+type silencer struct {
+	sync.Mutex
+	path     string
+	static   []Silence
+	adhoc    []Silence
+	modTime  time.Time
+	silenced map[string]bool
+}
+
+// Start returns the silencer of the settings
+func (s Silences) Start() *silencer {
+	return &silencer{path: s.Path, static: s.Static, silenced: map[string]bool{}}
+}
+
+// silences returns the static and ad-hoc silences
+func (s *silencer) silences() []Silence {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.path != "" {
+		info, err := os.Stat(s.path)
+		switch {
+		case os.IsNotExist(err):
+			s.adhoc, s.modTime = nil, time.Time{}
+		case err != nil:
+			log.Println(errors.Wrap(err, "error reading silences"))
+		case !info.ModTime().Equal(s.modTime):
+			adhoc, err := loadSilences(s.path)
+			if err != nil {
+				log.Println(err)
+				break
+			}
+			s.adhoc, s.modTime = adhoc, info.ModTime()
+		}
+	}
+
+	return append(append([]Silence{}, s.static...), s.adhoc...)
+}
+
+// Filter returns the alerts of the list which are not silenced, the silenced ones are
+// logged. A recovery is only silenced with its failure, so that the recipients of a failure
+// also get its recovery.
+func (s *silencer) Filter(a *AlertList) *AlertList {
+	silences := s.silences()
+
+	now := time.Now()
+	list := &AlertList{Alerts: []Alert{}, Fallback: a.Fallback}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, alert := range a.Alerts {
+		var silence *Silence
+		for i := range silences {
+			if silences[i].Matches(alert, now) {
+				silence = &silences[i]
+				break
+			}
+		}
+
+		switch {
+		case alert.State == StateRecovery && s.silenced[alert.Key()]:
+			log.Printf("silenced alert (failure silenced): %s\n", alert.Dump())
+			delete(s.silenced, alert.Key())
+
+		case alert.State == StateRecovery || silence == nil:
+			// the recipients of the failure also get its recovery
+			list.Alerts = append(list.Alerts, alert)
+			if alert.State == StateFailure {
+				delete(s.silenced, alert.Key())
+			}
+
+		default:
+			log.Printf("silenced alert (%s): %s\n", silence.Describe(), alert.Dump())
+			if alert.State == StateFailure && !alert.FollowUp {
+				s.silenced[alert.Key()] = true
+			}
+		}
+	}
+
+	return list
+}
+
+// Describe returns the identifier or the comment of a silence
+func (s Silence) Describe() string {
+	switch {
+	case s.ID != "" && s.Comment != "":
+		return s.ID + " " + s.Comment
+	case s.ID != "":
+		return s.ID
+	case s.Comment != "":
+		return s.Comment
+	default:
+		return s.Schedule
+	}
+}
+
+// silencesLock serializes the changes of the silences file made by the API
+var silencesLock sync.Mutex
+
+// loadSilences reads the ad-hoc silences file, a missing file has no silences
+func loadSilences(file string) ([]Silence, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return []Silence{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading silences")
+	}
+
+	silences := []Silence{}
+	if err := json.Unmarshal(b, &silences); err != nil {
+		return nil, errors.Wrapf(err, "error decoding silences %s", file)
+	}
+
+	return silences, nil
+}
+
+// saveSilences writes the ad-hoc silences file, the expired silences are removed
+func saveSilences(file string, silences []Silence) error {
+	active := []Silence{}
+	for _, s := range silences {
+		if s.Schedule != "" || time.Now().Before(s.End) {
+			active = append(active, s)
+		}
+	}
+
+	b, err := json.MarshalIndent(active, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// the file is replaced at once so that the daemon never reads a partial file
+	return errors.Wrap(writeFileAtomic(file, b), "error writing silences")
+}
+
+// AddSilence adds an ad-hoc silence to the file and returns it with its identifier
+func AddSilence(file string, s Silence) (Silence, error) {
+	if file == "" {
+		return s, ErrSilencesPath
+	}
+
+	if s.Start.IsZero() {
+		s.Start = time.Now()
+	}
+	if err := s.Valid(); err != nil {
+		return s, err
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return s, err
+	}
+	s.ID = hex.EncodeToString(id)
+
+	silencesLock.Lock()
+	defer silencesLock.Unlock()
+
+	silences, err := loadSilences(file)
+	if err != nil {
+		return s, err
+	}
+
+	return s, saveSilences(file, append(silences, s))
+}
+
+// RemoveSilence removes an ad-hoc silence from the file
+func RemoveSilence(file, id string) error {
+	if file == "" {
+		return ErrSilencesPath
+	}
+
+	silencesLock.Lock()
+	defer silencesLock.Unlock()
+
+	silences, err := loadSilences(file)
+	if err != nil {
+		return err
+	}
+
+	kept := []Silence{}
+	for _, s := range silences {
+		if s.ID != id {
+			kept = append(kept, s)
+		}
+	}
+
+	if len(kept) == len(silences) {
+		return errors.Wrap(ErrSilenceNotFound, id)
+	}
+
+	return saveSilences(file, kept)
+}
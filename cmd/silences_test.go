@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	cases := []struct {
+		schedule string
+		time     string
+		match    bool
+	}{
+		{"0 2 * * *", "2020-06-01T02:00:00Z", true},
+		{"0 2 * * *", "2020-06-01T02:01:00Z", false},
+		{"*/15 * * * *", "2020-06-01T13:45:00Z", true},
+		{"*/15 * * * *", "2020-06-01T13:46:00Z", false},
+		{"30 1-5 * * 1,3", "2020-06-03T04:30:00Z", true},  // wednesday
+		{"30 1-5 * * 1,3", "2020-06-04T04:30:00Z", false}, // thursday
+		{"0 0 * * 7", "2020-06-07T00:00:00Z", true},       // sunday
+		{"0 0 1 * 1", "2020-06-08T00:00:00Z", true},       // monday, not the 1st
+	}
+
+	for _, c := range cases {
+		s, err := parseCron(c.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tm, _ := time.Parse(time.RFC3339, c.time)
+		if s.Match(tm) != c.match {
+			t.Errorf("%q at %s: expected %v", c.schedule, c.time, c.match)
+		}
+	}
+
+	for _, invalid := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestSilenceMatches(t *testing.T) {
+	now := time.Date(2020, 6, 1, 2, 30, 0, 0, time.UTC)
+	alert := Alert{Container: "web-1", Check: CheckCPU, Labels: map[string]string{"env": "prod"}}
+
+	cases := []struct {
+		name    string
+		silence Silence
+		match   bool
+	}{
+		{"all", Silence{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}, true},
+		{"expired", Silence{Start: now.Add(-time.Hour), End: now}, false},
+		{"pattern", Silence{Container: "web-*", Start: now, End: now.Add(time.Hour)}, true},
+		{"other container", Silence{Container: "db", Start: now, End: now.Add(time.Hour)}, false},
+		{"label", Silence{Label: "env=prod", Start: now, End: now.Add(time.Hour)}, true},
+		{"label key", Silence{Label: "env", Start: now, End: now.Add(time.Hour)}, true},
+		{"other label", Silence{Label: "env=dev", Start: now, End: now.Add(time.Hour)}, false},
+		{"check", Silence{Check: CheckMemory, Start: now, End: now.Add(time.Hour)}, false},
+		{"window", Silence{Schedule: "0 2 * * *", Duration: 60, Timezone: "UTC"}, true},
+		{"window ended", Silence{Schedule: "0 2 * * *", Duration: 30, Timezone: "UTC"}, false},
+		{"timezone", Silence{Schedule: "0 4 * * *", Duration: 60, Timezone: "Europe/Paris"}, true},
+	}
+
+	for _, c := range cases {
+		if c.silence.Matches(alert, now) != c.match {
+			t.Errorf("%s: expected %v", c.name, c.match)
+		}
+	}
+}
+
+func TestSilencer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "silences")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "silences.json")
+	c := &Conf{Silences: Silences{Path: file}}
+	s := c.Silences.Start()
+
+	list := &AlertList{}
+	list.AddAlert(Alert{Container: "web", Check: CheckRunning, State: StateFailure})
+	list.AddAlert(Alert{Container: "db", Check: CheckRunning, State: StateFailure})
+
+	cpu := &AlertList{}
+	cpu.AddAlert(Alert{Container: "web", Check: CheckCPU, State: StateFailure})
+	if got := s.Filter(list); len(got.Alerts) != 2 || len(s.Filter(cpu).Alerts) != 1 {
+		t.Fatalf("expected no silenced alert, got %d alerts", len(got.Alerts))
+	}
+
+	// the silences added through the API are picked up by the running silencer
+	server := httptest.NewServer(API{Token: "secret"}.Handler(c))
+	defer server.Close()
+
+	body, _ := json.Marshal(Silence{Container: "web", End: time.Now().Add(time.Hour), Comment: "deploy"})
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/silences", bytes.NewReader(body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	// the API is never open without a token
+	if err := (API{Address: ":9842"}).Valid(); err == nil {
+		t.Error("expected an error for an api without token")
+	}
+	open := httptest.NewServer(API{}.Handler(c))
+	defer open.Close()
+	req, _ = http.NewRequest(http.MethodPost, open.URL+"/api/silences", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer ")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without configured token, got %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/api/silences", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var added Silence
+	json.NewDecoder(resp.Body).Decode(&added)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || added.ID == "" {
+		t.Fatalf("expected the silence to be added, got %d", resp.StatusCode)
+	}
+
+	got := s.Filter(list)
+	if len(got.Alerts) != 1 || got.Alerts[0].Container != "db" {
+		t.Fatalf("expected web to be silenced, got %+v", got.Alerts)
+	}
+
+	// only the recoveries of the silenced failures are silenced
+	recoveries := &AlertList{}
+	recoveries.AddAlert(Alert{Container: "web", Check: CheckRunning, State: StateRecovery})
+	recoveries.AddAlert(Alert{Container: "web", Check: CheckCPU, State: StateRecovery})
+	if got := s.Filter(recoveries); len(got.Alerts) != 1 || got.Alerts[0].Check != CheckCPU {
+		t.Fatalf("expected the recovery of the sent failure, got %+v", got.Alerts)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, server.URL+"/api/silences/"+added.ID, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the silence to be removed, got %d", resp.StatusCode)
+	}
+
+	if err := RemoveSilence(file, added.ID); err == nil {
+		t.Error("expected an error removing a missing silence")
+	}
+}
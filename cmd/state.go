@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// State contains the settings of the state file, when a path is set the state of the checks
+// is saved there and restored on start: the failures which are still active are not sent
+// again, the alerters keep replying to their messages, and the recoveries which happened
+// while the daemon was stopped are sent by the first checks.
+type State struct {
+	Path string
+}
+
+// CheckState is the saved state of a check
+type CheckState struct {
+	AlertActive bool             `json:"alertActive"`
+	ActiveSince time.Time        `json:"activeSince"`
+	Delaying    bool             `json:"delaying,omitempty"`
+	DelaySince  time.Time        `json:"delaySince"`
+	Ack         *Acknowledgement `json:"ack,omitempty"`
+}
+
+// stateFile is the content of the state file
+type stateFile struct {
+	Containers  map[string]map[string]CheckState `json:"containers"`
+	Alerts      []ActiveAlert                    `json:"alerts"`
+	Escalations map[string]escalationState       `json:"escalations,omitempty"`
+	Alerters    map[string]json.RawMessage       `json:"alerters,omitempty"`
+	Storm       *stormState                      `json:"storm,omitempty"`
+}
+
+// statefulAlerter is an alerter keeping the state of the active failures, such as the
+// messages to reply to or the notifications to cancel on recovery
+type statefulAlerter interface {
+	SaveState() ([]byte, error)
+	RestoreState(b []byte, active map[string]bool) error
+}
+
+// stateStore saves the state of the checks when it changes
+type stateStore struct {
+	path      string
+	escalator *escalator
+	alerters  []Alerter
+	stormer   *stormer
+	last      []byte
+}
+
+// Open returns the store of the state file, the notified tiers of the escalations, the state
+// of the alerters and the storm are saved with the checks
+func (s State) Open(c *Conf) *stateStore {
+	return &stateStore{path: s.Path, escalator: c.escalator, alerters: c.Alerters, stormer: c.stormer}
+}
+
+// State returns the state of a metric check
+func (c *MetricCheck) State() CheckState {
+	return CheckState{c.AlertActive, c.ActiveSince, c.Delaying, c.DelaySince, c.Ack}
+}
+
+// Restore sets the state of a metric check
+func (c *MetricCheck) Restore(s CheckState) {
+	c.AlertActive, c.ActiveSince, c.Delaying, c.DelaySince, c.Ack =
+		s.AlertActive, s.ActiveSince, s.Delaying, s.DelaySince, s.Ack
+}
+
+// State returns the state of a static check
+func (c *StaticCheck) State() CheckState {
+	return CheckState{c.AlertActive, c.ActiveSince, c.Delaying, c.DelaySince, c.Ack}
+}
+
+// Restore sets the state of a static check
+func (c *StaticCheck) Restore(s CheckState) {
+	c.AlertActive, c.ActiveSince, c.Delaying, c.DelaySince, c.Ack =
+		s.AlertActive, s.ActiveSince, s.Delaying, s.DelaySince, s.Ack
+}
+
+// checkStates returns the checks of the container by name
+func (c *AlertdContainer) checkStates() map[string]interface {
+	State() CheckState
+	Restore(CheckState)
+} {
+	return map[string]interface {
+		State() CheckState
+		Restore(CheckState)
+	}{
+		CheckExist:   c.ExistenceCheck,
+		CheckRunning: c.RunningCheck,
+		CheckCPU:     c.CPUCheck,
+		CheckMinPID:  c.PIDCheck,
+		CheckMemory:  c.MemCheck,
+	}
+}
+
+// Restore restores the state of the checks and their active alerts, the containers which
+// are not monitored anymore are ignored
+func (s *stateStore) Restore(cnt []AlertdContainer) error {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error reading the state")
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(b, &state); err != nil {
+		return errors.Wrapf(err, "error decoding the state %s", s.path)
+	}
+
+	active := map[string]bool{}
+	for _, c := range cnt {
+		for name, check := range c.checkStates() {
+			if saved, ok := state.Containers[c.Name][name]; ok {
+				check.Restore(saved)
+				active[c.Name+"/"+name] = saved.AlertActive
+			}
+		}
+	}
+
+	for _, alert := range state.Alerts {
+		if !active[alert.Alert.Key()] {
+			continue
+		}
+
+		activeAlerts.Restore(alert)
+		log.Printf("restored active alert: %s\n", alert.Alert.Dump())
+
+		// the tiers notified before the restart are not notified again
+		if e, ok := state.Escalations[alert.Alert.Key()]; ok && s.escalator != nil &&
+			e.Since.Equal(alert.Alert.Since) {
+			s.escalator.Restore(alert.Alert.Key(), e)
+		}
+	}
+
+	// the alerters reply to the messages and cancel the notifications of the restored failures
+	for _, alerter := range s.alerters {
+		stateful, ok := alerter.(statefulAlerter)
+		b, saved := state.Alerters[alerterName(alerter)]
+		if !ok || !saved {
+			continue
+		}
+
+		if err := stateful.RestoreState(b, active); err != nil {
+			return err
+		}
+	}
+	s.stormer.Restore(state.Storm, active)
+
+	s.last = b
+
+	return nil
+}
+
+// Save writes the state of the checks and the active alerts if it changed since the last
+// save
+func (s *stateStore) Save(cnt []AlertdContainer) error {
+	state := stateFile{Containers: map[string]map[string]CheckState{}, Alerts: activeAlerts.List()}
+	if s.escalator != nil {
+		state.Escalations = s.escalator.Reached()
+	}
+	for _, alerter := range s.alerters {
+		stateful, ok := alerter.(statefulAlerter)
+		if !ok {
+			continue
+		}
+
+		b, err := stateful.SaveState()
+		if err != nil {
+			return err
+		}
+		if b != nil {
+			if state.Alerters == nil {
+				state.Alerters = map[string]json.RawMessage{}
+			}
+			state.Alerters[alerterName(alerter)] = b
+		}
+	}
+	state.Storm = s.stormer.State()
+	for _, c := range cnt {
+		checks := map[string]CheckState{}
+		for name, check := range c.checkStates() {
+			checks[name] = check.State()
+		}
+		state.Containers[c.Name] = checks
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(b, s.last) {
+		return nil
+	}
+
+	if err := writeFileAtomic(s.path, b); err != nil {
+		return errors.Wrap(err, "error writing the state")
+	}
+	s.last = b
+
+	return nil
+}
+
+// writeFileAtomic replaces the file at once so that it is never read partially written
+func writeFileAtomic(file string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	defer func(r *alertRegistry) { activeAlerts = r }(activeAlerts)
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	settings := State{Path: path}
+
+	// nothing is restored on the first start
+	cnt := InitCheckers(&Conf{Containers: []Container{{Name: "web"}, {Name: "db"}}})
+	if err := settings.Open(&Conf{}).Restore(cnt); err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Now().Add(-time.Hour).Round(time.Second)
+	ack := &Acknowledgement{By: "alice", At: since.Add(time.Minute)}
+	cnt[0].CPUCheck.AlertActive, cnt[0].CPUCheck.ActiveSince, cnt[0].CPUCheck.Ack = true, since, ack
+	activeAlerts.Activate(Alert{Container: "web", Check: CheckCPU, State: StateFailure, Since: since})
+	activeAlerts.Acknowledge("web/"+CheckCPU, since, *ack)
+	activeAlerts.Activate(Alert{Container: "db", Check: CheckRunning, State: StateFailure})
+
+	e := newEscalator(nil, nil, nil)
+	e.Restore("web/"+CheckCPU, escalationState{Since: since, Tier: 1})
+	slack := Slack{threads: &slackThreads{threads: map[string]slackThread{
+		"web/" + CheckCPU:    {Channel: "C123", TS: "1.000"},
+		"db/" + CheckRunning: {Channel: "C123", TS: "2.000"},
+	}}}
+	storm := Storm{Threshold: 1}.Start()
+	storm.failures["web/"+CheckCPU] = Alert{Container: "web", Check: CheckCPU, State: StateFailure}
+	store := settings.Open(&Conf{escalator: e, Alerters: []Alerter{slack}, stormer: storm})
+	if err := store.Save(cnt); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state is only written when it changed
+	os.Chtimes(path, time.Unix(0, 0), time.Unix(0, 0))
+	if err := store.Save(cnt); err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, _ := os.Stat(path); unchanged.ModTime().Unix() != 0 {
+		t.Error("expected the unchanged state not to be written")
+	}
+
+	activeAlerts = &alertRegistry{alerts: map[string]*ActiveAlert{}}
+	cnt = InitCheckers(&Conf{Containers: []Container{{Name: "web"}}})
+	e = newEscalator(nil, nil, nil)
+	slack.threads.threads = map[string]slackThread{}
+	storm = Storm{Threshold: 1}.Start()
+	restored := &Conf{escalator: e, Alerters: []Alerter{slack}, stormer: storm}
+	if err := settings.Open(restored).Restore(cnt); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state of the alerters and the storm is restored for the active failures
+	threads := slack.threads.threads
+	if threads["web/"+CheckCPU].TS != "1.000" || len(threads) != 1 {
+		t.Errorf("expected the slack thread of web to be restored, got %+v", threads)
+	}
+	if _, ok := storm.failures["web/"+CheckCPU]; !ok {
+		t.Errorf("expected the storm failure to be restored, got %+v", storm.failures)
+	}
+
+	// the notified escalation tiers are restored with their failure
+	if reached := e.Reached(); reached["web/"+CheckCPU].Tier != 1 {
+		t.Errorf("expected the escalation tier to be restored, got %+v", reached)
+	}
+
+	check := cnt[0].CPUCheck
+	if !check.AlertActive || !check.ActiveSince.Equal(since) || check.Ack == nil || check.Ack.By != "alice" {
+		t.Errorf("unexpected restored check %+v", check)
+	}
+
+	// the alerts of the checks which are not active anymore are not restored
+	list := activeAlerts.List()
+	if len(list) != 1 || list[0].Alert.Key() != "web/"+CheckCPU || list[0].Ack == nil {
+		t.Errorf("unexpected restored alerts %+v", list)
+	}
+
+	ioutil.WriteFile(path, []byte("{"), 0644)
+	if err := settings.Open(&Conf{}).Restore(cnt); err == nil {
+		t.Error("expected an error for a corrupted state")
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Storm contains the storm protection settings. When more than Threshold containers fail
+// (or recover) in the same iteration, their alerts are replaced with a single storm alert
+// and logged in full, the storm recovers once all its failures recovered. A threshold of 0
+// disables the protection.
+type Storm struct {
+	Threshold uint64
+	Severity  string
+}
+
+// StormData is the data of the storm templates
+type StormData struct {
+	Count      int
+	Containers []string
+	Alerts     []Alert
+}
+
+// stormer summarizes the alert storms and resolves them once their failures recovered
+type stormer struct {
+	settings   Storm
+	failures   map[string]Alert
+	recoveries []Alert
+}
+
+// Start returns the stormer of the settings, there is none if the protection is disabled
+func (s Storm) Start() *stormer {
+	if s.Threshold == 0 {
+		return nil
+	}
+
+	return &stormer{settings: s, failures: map[string]Alert{}}
+}
+
+// Summarize replaces the failures, and the recoveries, of the list with a storm alert when
+// they concern more containers than the threshold. The recoveries of the summarized failures
+// are replaced with the recovery of the storm once they all recovered.
+func (s *stormer) Summarize(a *AlertList, t *TemplateConfig) {
+	alerts := []Alert{}
+	for _, alert := range a.Alerts {
+		if _, ok := s.failures[alert.Key()]; ok && alert.State == StateRecovery {
+			delete(s.failures, alert.Key())
+			s.recoveries = append(s.recoveries, alert)
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	a.Alerts = alerts
+
+	for _, state := range []AlertState{StateFailure, StateRecovery} {
+		storm := []Alert{}
+		for _, alert := range a.Alerts {
+			// the reminders and escalations follow up on failures which were already counted
+			if alert.State == state && alert.Container != "" && !alert.FollowUp {
+				storm = append(storm, alert)
+			}
+		}
+
+		data := stormData(storm)
+		if uint64(data.Count) <= s.settings.Threshold {
+			continue
+		}
+
+		log.Printf("alert storm: %d containers in %s state, the alerts are summarized\n",
+			data.Count, state)
+		for _, alert := range storm {
+			alert.Log()
+		}
+
+		alerts := []Alert{}
+		for _, alert := range a.Alerts {
+			if alert.State != state || alert.Container == "" || alert.FollowUp {
+				alerts = append(alerts, alert)
+			} else if state == StateFailure {
+				s.failures[alert.Key()] = alert
+			}
+		}
+
+		a.Alerts = alerts
+		a.AddAlert(s.settings.alert(state, data, t))
+	}
+
+	if len(s.recoveries) != 0 && len(s.failures) == 0 {
+		log.Println("alert storm recovered")
+		a.AddAlert(s.settings.alert(StateRecovery, stormData(s.recoveries), t))
+		s.recoveries = nil
+	}
+}
+
+// Summarize summarizes the alert storms of the list
+func (c *Conf) Summarize(a *AlertList) {
+	if c.stormer != nil {
+		c.stormer.Summarize(a, &c.Templates)
+	}
+}
+
+// stormState is the saved state of a storm which has not recovered yet
+type stormState struct {
+	Failures   map[string]Alert `json:"failures"`
+	Recoveries []Alert          `json:"recoveries,omitempty"`
+}
+
+// State returns the state of the storm, there is none without summarized failures
+func (s *stormer) State() *stormState {
+	if s == nil || len(s.failures) == 0 {
+		return nil
+	}
+
+	return &stormState{Failures: s.failures, Recoveries: s.recoveries}
+}
+
+// Restore restores the summarized failures which are still active
+func (s *stormer) Restore(state *stormState, active map[string]bool) {
+	if s == nil || state == nil {
+		return
+	}
+
+	for key, alert := range state.Failures {
+		if active[key] {
+			s.failures[key] = alert
+		}
+	}
+	s.recoveries = state.Recoveries
+}
+
+// stormData returns the data of the storm of the alerts
+func stormData(alerts []Alert) StormData {
+	data := StormData{Alerts: alerts}
+
+	containers := map[string]bool{}
+	for _, alert := range alerts {
+		if !containers[alert.Container] {
+			containers[alert.Container] = true
+			data.Containers = append(data.Containers, alert.Container)
+		}
+	}
+	sort.Strings(data.Containers)
+	data.Count = len(data.Containers)
+
+	return data
+}
+
+// alert renders the storm alert of a state
+func (s Storm) alert(state AlertState, data StormData, t *TemplateConfig) Alert {
+	var message bytes.Buffer
+	var title bytes.Buffer
+
+	t.Executor.ExecuteTemplate(&message, CheckStorm+"-"+string(state)+"-message", data)
+	t.Executor.ExecuteTemplate(&title, CheckStorm+"-"+string(state)+"-title", data)
+
+	severity := s.Severity
+	if severity == "" {
+		severity = DefaultSeverity
+	}
+
+	since := time.Now()
+	for _, alert := range data.Alerts {
+		if !alert.Since.IsZero() && alert.Since.Before(since) {
+			since = alert.Since
+		}
+	}
+
+	return Alert{
+		Message:  strings.TrimSpace(message.String()),
+		Title:    title.String(),
+		Check:    CheckStorm,
+		State:    state,
+		Severity: severity,
+		Since:    since,
+	}
+}
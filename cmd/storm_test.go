@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStormSummarize(t *testing.T) {
+	templates, err := TemplateConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &AlertList{}
+	for _, c := range []string{"web", "db", "cache"} {
+		a.AddAlert(Alert{Title: "Existence check failure", Container: c, Check: CheckExist,
+			State: StateFailure})
+	}
+	a.AddAlert(Alert{Title: "CPU check failure", Container: "web", Check: CheckCPU, State: StateFailure})
+	a.AddAlert(Alert{Title: "CPU check recovered", Container: "api", Check: CheckCPU, State: StateRecovery})
+
+	Storm{Threshold: 3}.Start().Summarize(a, &templates)
+	if a.Len() != 5 {
+		t.Fatalf("3 containers should not be a storm, got %d alerts", a.Len())
+	}
+
+	// the reminders are not counted
+	a.AddAlert(Alert{Title: "Reminder: CPU check failure", Container: "api", Check: CheckCPU,
+		State: StateFailure, FollowUp: true})
+
+	s := Storm{Threshold: 2}.Start()
+	s.Summarize(a, &templates)
+	if a.Len() != 3 {
+		t.Fatalf("expected the recovery, the reminder and the storm alert, got %+v", a.Alerts)
+	}
+
+	if a.Alerts[0].Container != "api" || a.Alerts[1].Container != "api" {
+		t.Errorf("the recovery and the reminder should be kept, got %+v", a.Alerts[:2])
+	}
+
+	storm := a.Alerts[2]
+	if storm.Title != "Alert storm: 3 containers failing" || storm.Check != CheckStorm ||
+		storm.State != StateFailure || storm.Severity != DefaultSeverity {
+		t.Errorf("unexpected storm alert: %+v", storm)
+	}
+
+	if lines := strings.Split(storm.Message, "\n"); len(lines) != 4 || lines[3] != "web: CPU check failure" {
+		t.Errorf("unexpected storm message: %q", storm.Message)
+	}
+
+	// the storm recovers with the last of its failures
+	for i, c := range []string{"web", "db", "cache"} {
+		a = &AlertList{}
+		a.AddAlert(Alert{Title: "Existence check recovered", Container: c, Check: CheckExist,
+			State: StateRecovery})
+		if c == "web" {
+			a.AddAlert(Alert{Title: "CPU check recovered", Container: c, Check: CheckCPU,
+				State: StateRecovery})
+		}
+		s.Summarize(a, &templates)
+
+		if i < 2 && a.Len() != 0 {
+			t.Fatalf("expected the recoveries to wait for the storm, got %+v", a.Alerts)
+		}
+	}
+	if a.Len() != 1 || a.Alerts[0].Title != "Alert storm: 3 containers recovered" ||
+		a.Alerts[0].State != StateRecovery {
+		t.Errorf("expected the recovery of the storm, got %+v", a.Alerts)
+	}
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// syslog severities as defined by RFC5424
+const (
+	syslogCrit    = 2
+	syslogErr     = 3
+	syslogWarning = 4
+	syslogNotice  = 5
+	syslogInfo    = 6
+)
+
+// syslogFacilities maps the facility names to their RFC5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6,
+	"news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "local0": 16,
+	"local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22,
+	"local7": 23,
+}
+
+// syslogSockets are the usual paths of the local syslog socket
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogSDID is the structured data ID of the alerts, 32473 is the enterprise number
+// reserved for documentation by IANA
+const syslogSDID = "alertd@32473"
+
+// alertPriority returns the syslog severity of an alert, failures use the severity of their
+// container while recoveries are notices
+func alertPriority(a Alert) int {
+	switch a.State {
+	case StateFailure:
+		switch a.Severity {
+		case "critical":
+			return syslogCrit
+		case "warning":
+			return syslogWarning
+		default:
+			return syslogErr
+		}
+	case StateRecovery:
+		return syslogNotice
+	default:
+		if a.Error != nil {
+			return syslogErr
+		}
+		return syslogInfo
+	}
+}
+
+// alertText returns the title and the message of an alert on a single line
+func alertText(a Alert) string {
+	a.Title = strings.TrimSpace(a.Title)
+	a.Message = strings.TrimSpace(a.Message)
+
+	return a.Dump()
+}
+
+// Syslog contains all info needed to send the alerts to a local or remote syslog server
+type Syslog struct {
+	Network  string
+	Address  string
+	Facility string
+	Tag      string
+}
+
+// Valid returns an error if syslog settings are invalid
+func (s Syslog) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Syslog{}, s) {
+		return nil // assume that syslog was omitted
+	}
+
+	switch s.Network {
+	case "", "unix", "unixgram":
+	case "udp", "tcp":
+		if s.Address == "" {
+			errString = append(errString, ErrSyslogAddress.Error())
+		}
+	default:
+		errString = append(errString, ErrSyslogNetwork.Error())
+	}
+
+	if _, ok := syslogFacilities[s.Facility]; s.Facility != "" && !ok {
+		errString = append(errString, ErrSyslogFacility.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "syslog settings validation fail")
+}
+
+// dial connects to the syslog server, the local socket is searched when no address is set
+func (s Syslog) dial(ctx context.Context) (net.Conn, error) {
+	if s.Address != "" {
+		network := s.Network
+		if network == "" {
+			network = "unixgram"
+		}
+
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		return dialer.DialContext(ctx, network, s.Address)
+	}
+
+	for _, path := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if s.Network != "" && s.Network != network {
+				continue
+			}
+
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, errors.New("no local syslog socket found")
+}
+
+// Format returns the RFC5424 message of an alert, the container, check and severity are
+// added as structured data
+func (s Syslog) Format(a Alert) string {
+	facility := syslogFacilities[s.Facility]
+	if s.Facility == "" {
+		facility = syslogFacilities["daemon"]
+	}
+
+	tag := s.Tag
+	if tag == "" {
+		tag = "docker-alertd"
+	}
+
+	host := a.Host
+	if host == "" {
+		host = "-"
+	}
+
+	msgID := a.Check
+	if msgID == "" {
+		msgID = "-"
+	}
+
+	var sd bytes.Buffer
+	sd.WriteString("[" + syslogSDID)
+	for _, param := range [][2]string{
+		{"container", a.Container},
+		{"check", a.Check},
+		{"state", string(a.State)},
+		{"severity", a.Severity},
+	} {
+		if param[1] != "" {
+			fmt.Fprintf(&sd, " %s=\"%s\"", param[0], syslogEscape(param[1]))
+		}
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s", facility*8+alertPriority(a),
+		a.Time.Format(time.RFC3339Nano), host, tag, os.Getpid(), msgID, sd.String(),
+		alertText(a))
+}
+
+// syslogEscape escapes the characters which are not allowed in a structured data value
+func syslogEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// Alert sends every alert of the list as a syslog message
+func (s Syslog) Alert(ctx context.Context, a *AlertList) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error connecting to syslog")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	for _, alert := range a.Alerts {
+		msg := s.Format(alert)
+
+		// stream transports need some framing, octet counting (RFC6587) for remote servers
+		// and a trailing newline for local sockets
+		switch conn.RemoteAddr().Network() {
+		case "tcp":
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		case "unix":
+			msg += "\n"
+		}
+
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return errors.Wrap(err, "error sending alert to syslog")
+		}
+	}
+
+	log.Println("sent alert to syslog")
+	return nil
+}
+
+// Journald contains all info needed to send the alerts to the systemd journal
+type Journald struct {
+	Socket     string
+	Identifier string
+}
+
+// Valid returns an error if journald settings are invalid
+func (j Journald) Valid() error {
+	return nil // every setting has a default value
+}
+
+// Fields returns the journal fields of an alert
+func (j Journald) Fields(a Alert) [][2]string {
+	identifier := j.Identifier
+	if identifier == "" {
+		identifier = "docker-alertd"
+	}
+
+	fields := [][2]string{
+		{"MESSAGE", alertText(a)},
+		{"PRIORITY", fmt.Sprintf("%d", alertPriority(a))},
+		{"SYSLOG_IDENTIFIER", identifier},
+	}
+
+	for _, field := range [][2]string{
+		{"ALERTD_TITLE", strings.TrimSpace(a.Title)},
+		{"ALERTD_CONTAINER", a.Container},
+		{"ALERTD_CHECK", a.Check},
+		{"ALERTD_STATE", string(a.State)},
+		{"ALERTD_SEVERITY", a.Severity},
+		{"ALERTD_HOST", a.Host},
+	} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// Alert sends every alert of the list to the journal using its native protocol
+func (j Journald) Alert(ctx context.Context, a *AlertList) error {
+	socket := j.Socket
+	if socket == "" {
+		socket = "/run/systemd/journal/socket"
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unixgram", socket)
+	if err != nil {
+		return errors.Wrap(err, "error connecting to journald")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	for _, alert := range a.Alerts {
+		var b bytes.Buffer
+
+		for _, field := range j.Fields(alert) {
+			if !strings.Contains(field[1], "\n") {
+				fmt.Fprintf(&b, "%s=%s\n", field[0], field[1])
+				continue
+			}
+
+			// values with newlines are sent with their size as a little endian uint64
+			b.WriteString(field[0] + "\n")
+			binary.Write(&b, binary.LittleEndian, uint64(len(field[1])))
+			b.WriteString(field[1] + "\n")
+		}
+
+		if _, err := conn.Write(b.Bytes()); err != nil {
+			return errors.Wrap(err, "error sending alert to journald")
+		}
+	}
+
+	log.Println("sent alert to journald")
+	return nil
+}
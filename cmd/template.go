@@ -22,6 +22,14 @@ type TemplateConfig struct {
 	MinPIDRecovery		AlertTemplate
 	MemoryFailure		AlertTemplate
 	MemoryRecovery		AlertTemplate
+	Fallback			AlertTemplate
+	StormFailure		AlertTemplate
+	StormRecovery		AlertTemplate
+	Reminder			AlertTemplate
+	Acknowledged		AlertTemplate
+	Escalation			AlertTemplate
+	DaemonFailure		AlertTemplate
+	DaemonRecovery		AlertTemplate
 	Executor			template.Template
 }
 
@@ -258,5 +266,193 @@ func (t TemplateConfig) Build() (TemplateConfig, error) {
 	}
 	// }}}
 	
+	// {{{ Storm
+	if t.StormFailure.Message == "" {
+		_, err = t.Executor.New("storm-failure-message").Parse(DefaultStormMessage)
+	} else {
+		_, err = t.Executor.New("storm-failure-message").Parse(t.StormFailure.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.StormFailure.Title == "" {
+		_, err = t.Executor.New("storm-failure-title").Parse("Alert storm: {{.Count}} containers failing")
+	} else {
+		_, err = t.Executor.New("storm-failure-title").Parse(t.StormFailure.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.StormRecovery.Message == "" {
+		_, err = t.Executor.New("storm-recovery-message").Parse(DefaultStormMessage)
+	} else {
+		_, err = t.Executor.New("storm-recovery-message").Parse(t.StormRecovery.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.StormRecovery.Title == "" {
+		_, err = t.Executor.New("storm-recovery-title").Parse("Alert storm: {{.Count}} containers recovered")
+	} else {
+		_, err = t.Executor.New("storm-recovery-title").Parse(t.StormRecovery.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	// }}}
+	
+	// {{{ Fallback
+	if t.Fallback.Message == "" {
+		_, err = t.Executor.New("fallback-message").Parse("{{.Failed}} failed ({{.Error}}), sent to {{.Alerter}} instead")
+	} else {
+		_, err = t.Executor.New("fallback-message").Parse(t.Fallback.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.Fallback.Title == "" {
+		_, err = t.Executor.New("fallback-title").Parse("Fallback delivery")
+	} else {
+		_, err = t.Executor.New("fallback-title").Parse(t.Fallback.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	// }}}
+	
+	// {{{ Reminder
+	if t.Reminder.Message == "" {
+		_, err = t.Executor.New("reminder-message").Parse("{{.Message}}")
+	} else {
+		_, err = t.Executor.New("reminder-message").Parse(t.Reminder.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.Reminder.Title == "" {
+		_, err = t.Executor.New("reminder-title").Parse("Reminder: {{.Title}}")
+	} else {
+		_, err = t.Executor.New("reminder-title").Parse(t.Reminder.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	// }}}
+	
+	// {{{ Acknowledged
+	if t.Acknowledged.Message == "" {
+		_, err = t.Executor.New("acknowledged-message").Parse("{{.Container}} {{.Check}} acknowledged by {{.By}}{{with .Comment}}: {{.}}{{end}}")
+	} else {
+		_, err = t.Executor.New("acknowledged-message").Parse(t.Acknowledged.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.Acknowledged.Title == "" {
+		_, err = t.Executor.New("acknowledged-title").Parse("Acknowledged: {{.Title}}")
+	} else {
+		_, err = t.Executor.New("acknowledged-title").Parse(t.Acknowledged.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	// }}}
+	
+	// {{{ Escalation
+	if t.Escalation.Message == "" {
+		_, err = t.Executor.New("escalation-message").Parse("{{.Message}}\nNot acknowledged for {{.Minutes}} minutes")
+	} else {
+		_, err = t.Executor.New("escalation-message").Parse(t.Escalation.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.Escalation.Title == "" {
+		_, err = t.Executor.New("escalation-title").Parse("Escalated: {{.Title}}")
+	} else {
+		_, err = t.Executor.New("escalation-title").Parse(t.Escalation.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	// }}}
+	
+	// {{{ Daemon
+	if t.DaemonFailure.Message == "" {
+		_, err = t.Executor.New("daemon-failure-message").Parse("{{.Error}}")
+	} else {
+		_, err = t.Executor.New("daemon-failure-message").Parse(t.DaemonFailure.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.DaemonFailure.Title == "" {
+		_, err = t.Executor.New("daemon-failure-title").Parse(ErrDaemonCheckFail.Error())
+	} else {
+		_, err = t.Executor.New("daemon-failure-title").Parse(t.DaemonFailure.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.DaemonRecovery.Message == "" {
+		_, err = t.Executor.New("daemon-recovery-message").Parse("docker {{.Version}} back after {{.Downtime}}, latency {{.Latency}}")
+	} else {
+		_, err = t.Executor.New("daemon-recovery-message").Parse(t.DaemonRecovery.Message)
+	}
+	if err != nil {
+		return t, err
+	}
+	
+	if t.DaemonRecovery.Title == "" {
+		_, err = t.Executor.New("daemon-recovery-title").Parse(ErrDaemonCheckRecovered.Error())
+	} else {
+		_, err = t.Executor.New("daemon-recovery-title").Parse(t.DaemonRecovery.Title)
+	}
+	if err != nil {
+		return t, err
+	}
+	// }}}
+	
 	return t, nil
-}
\ No newline at end of file
+}
+// DefaultStormMessage lists the alerts summarized by a storm alert
+const DefaultStormMessage = `{{range .Alerts}}{{.Container}}: {{.Title}}
+{{end}}`
+
+// DefaultEmailHTMLTemplate is the HTML part of the emails, it can be replaced with the
+// htmlTemplate setting of the email alerter. The rows are colored by state and the usage
+// and limit are only known for the metric checks.
+const DefaultEmailHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title>{{.Subject}}</title>
+</head>
+<body style="font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #212529;">
+<h2 style="font-size: 18px;">{{.Subject}}</h2>
+{{with .Host}}<p>Host: <strong>{{.}}</strong></p>{{end}}
+<table cellpadding="6" cellspacing="0" style="border-collapse: collapse; border: 1px solid #dee2e6;">
+<tr style="background-color: #343a40; color: #ffffff; text-align: left;">
+<th>Container</th><th>Check</th><th>State</th><th>Usage / Limit</th><th>Since</th><th>Message</th>
+</tr>
+{{$date := .Date}}{{range .Alerts}}<tr style="background-color: {{if eq .State "failure"}}#f8d7da{{else if eq .State "recovery"}}#d4edda{{else}}#e2e3e5{{end}}; border-top: 1px solid #dee2e6;">
+<td>{{.Container}}</td>
+<td>{{.Check}}</td>
+<td>{{.State}}</td>
+<td>{{if .Value}}{{.Value}} / {{.Limit}}{{end}}</td>
+<td>{{if ne .State "info"}}{{since .Since $date}}{{end}}</td>
+<td style="white-space: pre-line;"><strong>{{.Title}}</strong><br>{{.Message}}{{with .Error}}<br>{{.}}{{end}}{{with .AckURL}}<br><a href="{{.}}">Acknowledge</a>{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`
@@ -1,14 +1,99 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"html"
 	"log"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
+// AlertState describes whether an alert reports a failing check, a recovered check or is a
+// simple informational message (starting, stopping, unknown errors)
+type AlertState string
+
+// the states also match the templates names, "cpu-failure-title" for example
+const (
+	StateFailure  AlertState = "failure"
+	StateRecovery AlertState = "recovery"
+	StateInfo     AlertState = "info"
+)
+
+// the names of the checks, they are also the prefix of the templates names
+const (
+	CheckExist   = "exist"
+	CheckRunning = "running"
+	CheckCPU     = "cpu"
+	CheckMinPID  = "min-pid"
+	CheckMemory  = "memory"
+	CheckStorm   = "storm"
+	CheckDaemon  = "daemon"
+)
+
+// DefaultSeverity is the severity of the alerts of a container without a configured one
+const DefaultSeverity = "critical"
+
 type Alert struct {
-	Message	string
-	Title	string
-	Error	error
+	Message	string	`json:"message"`
+	Title	string	`json:"title"`
+	Error	error	`json:"-"`
+	Container	string	`json:"container,omitempty"`
+	Project	string	`json:"project,omitempty"`
+	Labels	map[string]string	`json:"labels,omitempty"`
+	Check	string	`json:"check,omitempty"`
+	State	AlertState	`json:"state"`
+	Severity	string	`json:"severity,omitempty"`
+	Host	string	`json:"host,omitempty"`
+	Time	time.Time	`json:"time"`
+	Since	time.Time	`json:"since"`
+	Value	*uint64	`json:"value,omitempty"`
+	Limit	*uint64	`json:"limit,omitempty"`
+	AckURL	string	`json:"ackUrl,omitempty"`
+	CausedBy	[]string	`json:"causedBy,omitempty"`
+	FollowUp	bool	`json:"followUp,omitempty"`
+}
+
+// MarshalJSON encodes the alert with its error as a string
+func (a Alert) MarshalJSON() ([]byte, error) {
+	type alert Alert
+	
+	errString := ""
+	if a.Error != nil {
+		errString = a.Error.Error()
+	}
+	
+	return json.Marshal(struct {
+		alert
+		Error	string	`json:"error,omitempty"`
+	}{alert(a), errString})
+}
+
+// UnmarshalJSON decodes an alert encoded by MarshalJSON, the error is restored from its text
+func (a *Alert) UnmarshalJSON(b []byte) error {
+	type alert Alert
+
+	v := struct {
+		*alert
+		Error string `json:"error"`
+	}{alert: (*alert)(a)}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	if v.Error != "" {
+		a.Error = errors.New(v.Error)
+	}
+
+	return nil
+}
+
+// Key identifies the check of a container which has raised the alert
+func (a *Alert) Key() string {
+	return a.Container + "/" + a.Check
 }
 
 func (a *Alert) Log() {
@@ -44,13 +129,18 @@ func (a *Alert) DumpEmail() (s string) {
 		s += "\n" + a.Error.Error()
 	}
 	
+	if a.AckURL != "" {
+		s += "\nAcknowledge: " + a.AckURL
+	}
+	
 	return s
 }
 
 // AlertList is the struct that stores information about alerts and its methods satisfy the
 // Alerter interface
 type AlertList struct {
-	Alerts        []Alert
+	Alerts        []Alert	`json:"alerts"`
+	Fallback      *Fallback	`json:"fallback,omitempty"`
 }
 
 // ShouldSend returns true if there is an alert message to be sent
@@ -60,8 +150,13 @@ func (a *AlertList) ShouldSend() bool {
 
 // Evaluate will check if error should be sent and then trigger it if necessary
 func (a *AlertList) Evaluate() {
-	if a.ShouldSend() {
-		a.Send(Config.Alerters)
+	switch {
+	case !a.ShouldSend():
+		return
+	case Config.digest != nil:
+		Config.digest.Add(a)
+	default:
+		a.Send(Config.Routes())
 	}
 }
 
@@ -72,7 +167,19 @@ func (a *AlertList) Len() int {
 
 // Add should take in an error and wrap it
 func (a *AlertList) Add(message string, title string, e error) {
-	a.Alerts = append(a.Alerts, Alert{Message: message, Title: title, Error: e})
+	a.AddAlert(Alert{Message: message, Title: title, Error: e, State: StateInfo})
+}
+
+// AddAlert appends an alert to the list, filling the host and the time if they are missing
+func (a *AlertList) AddAlert(alert Alert) {
+	if alert.Host == "" {
+		alert.Host = Config.Hostname
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	
+	a.Alerts = append(a.Alerts, alert)
 }
 
 // Concat will concat different alerts from containers together into one
@@ -120,6 +227,27 @@ func (a *AlertList) DumpEmail() (s string) {
 	return s
 }
 
+// DumpHTML formats the alerts with their title in bold, for the chat alerters
+func (a *AlertList) DumpHTML() (s string) {
+	for i, alert := range a.Alerts {
+		if i > 0 {
+			s += "<br><br>"
+		}
+
+		if title := strings.TrimSpace(alert.Title); title != "" {
+			s += "<strong>" + html.EscapeString(title) + "</strong><br>"
+		}
+
+		s += strings.Replace(html.EscapeString(strings.TrimSpace(alert.Message)), "\n", "<br>", -1)
+
+		if alert.Error != nil {
+			s += "<br>" + html.EscapeString(alert.Error.Error())
+		}
+	}
+
+	return s
+}
+
 func (a *AlertList) Message() (s string) {
 	for _, alert := range a.Alerts {
 		s += alert.Message + " "
@@ -136,16 +264,89 @@ func (a *AlertList) Title() (s string) {
 	return s
 }
 
-// Send is for sending out alerts to syslog and to alerts that are active in conf
-func (a *AlertList) Send(b []Alerter) {
+// State returns the state of the whole list, a single failure makes it a failure and
+// recoveries take precedence over informational alerts
+func (a *AlertList) State() AlertState {
+	state := StateInfo
+	for _, alert := range a.Alerts {
+		switch alert.State {
+		case StateFailure:
+			return StateFailure
+		case StateRecovery:
+			state = StateRecovery
+		}
+	}
+
+	return state
+}
+
+// Send is for sending out alerts to syslog and to alerts that are active in conf, each
+// alerter retries according to the delivery settings and its fallbacks are used if it still
+// fails. With a queue, the alerts are stored on disk first and sent in order by the queue.
+// The silenced alerts are not sent, the escalation policies choose the alerters of their
+// alerts and the alerters in their quiet hours hold the others.
+func (a *AlertList) Send(routes []AlerterChain) {
 	a.Log()
 	
-	for i := range b {
-		go func(c Alerter) {
-			err := c.Alert(a)
-			if err != nil {
-				log.Println(err)
+	// the list is cleared by the monitor loop while the alerts are being sent
+	list := &AlertList{Alerts: append([]Alert{}, a.Alerts...)}
+	
+	// the tiers of the recovered checks are forgotten once their recoveries are routed
+	if Config.escalator != nil {
+		defer Config.escalator.Forget(list)
+	}
+	
+	// the silenced alerts are logged but not delivered
+	if Config.silencer != nil {
+		list = Config.silencer.Filter(list)
+		if !list.ShouldSend() {
+			return
+		}
+	}
+	
+	if len(Config.quiet) == 0 && Config.escalator == nil {
+		Config.deliver(routes, list)
+		return
+	}
+	
+	// the escalation policies choose the chains of their alerts and the chains in their
+	// quiet hours hold some of the alerts
+	for _, chain := range routes {
+		l := list
+		if Config.escalator != nil {
+			if l = Config.escalator.Filter(chain, l); !l.ShouldSend() {
+				continue
+			}
+		}
+		if h, ok := Config.quiet[chain.Name()]; ok {
+			if l = h.Hold(l); !l.ShouldSend() {
+				continue
 			}
-		}(b[i])
+		}
+		Config.deliver([]AlerterChain{chain}, l)
+	}
+}
+
+// deliver sends the alerts to the alerter chains, through the queue if there is one
+func (c *Conf) deliver(routes []AlerterChain, list *AlertList) {
+	if c.queue != nil {
+		names := []string{}
+		for _, chain := range routes {
+			names = append(names, chain.Name())
+		}
+		
+		err := c.queue.Enqueue(list, names...)
+		if err == nil {
+			return
+		}
+		log.Println(errors.Wrap(err, "error queueing alert, sending it directly"))
+	}
+	
+	for i := range routes {
+		deliveries.Add(1)
+		go func(chain AlerterChain) {
+			defer deliveries.Done()
+			c.Delivery.Deliver(context.Background(), chain, list)
+		}(routes[i])
 	}
 }
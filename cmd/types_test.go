@@ -63,6 +63,84 @@ func TestConfigValidate(t *testing.T) {
 			},
 			ExpectedErr: ErrEmailNoFrom,
 		},
+		{
+			Name: "config with email relay without password passes",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name: "some_container",
+					},
+				},
+				Email: Email{
+					From:    "some@email.com",
+					Port:    "25",
+					SMTP:    "relay.someserver.com",
+					Subject: "MY SUBJECT",
+					BCC:     []string{"me@email.com"},
+				},
+			},
+			ExpectedErr: nil,
+		},
+		{
+			Name: "config with email login auth without password fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name: "some_container",
+					},
+				},
+				Email: Email{
+					Auth:    "login",
+					From:    "some@email.com",
+					Port:    "587",
+					SMTP:    "smtp@someserver.com",
+					Subject: "MY SUBJECT",
+					To:      []string{"me@email.com"},
+				},
+			},
+			ExpectedErr: ErrEmailNoPass,
+		},
+		{
+			Name: "config with alertmanager without url fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name: "some_container",
+					},
+				},
+				Alertmanager: Alertmanager{
+					RepeatInterval: 60,
+				},
+			},
+			ExpectedErr: ErrAlertmanagerURL,
+		},
+		{
+			Name: "config with slack bot token without channel fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name: "some_container",
+					},
+				},
+				Slack: Slack{
+					Token: "xoxb-token",
+				},
+			},
+			ExpectedErr: ErrSlackNoChannel,
+		},
+		{
+			Name: "config with fallback to an unknown alerter fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name: "some_container",
+					},
+				},
+				Journald:  Journald{Identifier: "alertd"},
+				Fallbacks: []string{"journald -> slack"},
+			},
+			ExpectedErr: ErrFallbackUnknown,
+		},
 	}
 
 	for _, test := range tests {